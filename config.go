@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	cli "github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are looked up in the working directory when --config is not given.
+var configFiles = []string{"migrate.yaml", "migrate.yml", "migrate.json"}
+
+// config represents migrate.yaml or migrate.json.
+type config struct {
+	// Default is the environment used when --env is not given.
+	Default      string                  `yaml:"default" json:"default"`
+	Environments map[string]*environment `yaml:"environments" json:"environments"`
+}
+
+// environment is a named set of connection settings.
+// Relative paths are resolved from the directory of the config file.
+type environment struct {
+	// URI is the connection string, URIEnv names an environment variable holding it,
+	// and URIFile is a file holding it, so secrets can be kept out of the config file.
+	URI           string   `yaml:"uri" json:"uri"`
+	URIEnv        string   `yaml:"uriEnv" json:"uriEnv"`
+	URIFile       string   `yaml:"uriFile" json:"uriFile"`
+	Provider      string   `yaml:"provider" json:"provider"`
+	ResourceGroup string   `yaml:"resourceGroup" json:"resourceGroup"`
+	AccountName   string   `yaml:"accountName" json:"accountName"`
+	Subscription  string   `yaml:"subscription" json:"subscription"`
+	AzureClient   string   `yaml:"azureClient" json:"azureClient"`
+	ARMEndpoint   string   `yaml:"armEndpoint" json:"armEndpoint"`
+	Dir           string   `yaml:"dir" json:"dir"`
+	Timeout       duration `yaml:"timeout" json:"timeout"`
+	AzTimeout     duration `yaml:"azTimeout" json:"azTimeout"`
+	LockTTL       duration `yaml:"lockTTL" json:"lockTTL"`
+}
+
+// duration reads time.Duration written as string such as "30s" from both YAML and JSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+
+	if err != nil {
+		return err
+	}
+
+	if v <= 0 {
+		return fmt.Errorf("duration must be positive, got %s", v)
+	}
+
+	*d = duration(v)
+
+	return nil
+}
+
+// loadConfig reads the config file, the format is decided by its extension.
+func loadConfig(name string) (*config, error) {
+	b, err := os.ReadFile(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg config
+
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(&cfg)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s, %s", name, err)
+	}
+
+	base := filepath.Dir(name)
+
+	for _, env := range cfg.Environments {
+		if env == nil {
+			continue
+		}
+
+		env.Dir = resolvePath(base, env.Dir)
+		env.URIFile = resolvePath(base, env.URIFile)
+	}
+
+	return &cfg, nil
+}
+
+func resolvePath(base, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(base, p)
+}
+
+// environment returns the named environment, or the default one when name is empty.
+func (cfg *config) environment(name string) (*environment, error) {
+	if name == "" {
+		name = cfg.Default
+	}
+
+	if name == "" {
+		return &environment{}, nil
+	}
+
+	env, ok := cfg.Environments[name]
+
+	if !ok || env == nil {
+		names := make([]string, 0, len(cfg.Environments))
+
+		for k := range cfg.Environments {
+			names = append(names, k)
+		}
+
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("environment %s is not defined, available: %s", name, strings.Join(names, ", "))
+	}
+
+	return env, nil
+}
+
+// findConfig returns path of the config file, empty when there is none.
+func findConfig(c *cli.Context) (string, error) {
+	if c.IsSet("config") {
+		return c.String("config"), nil
+	}
+
+	for _, name := range configFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+
+	if c.IsSet("env") {
+		return "", fmt.Errorf("--env is given but none of %s is found", strings.Join(configFiles, ", "))
+	}
+
+	return "", nil
+}
+
+// settings are values to connect with, resolved in order of flags, environment variables,
+// the environment of the config file and defaults of flags.
+type settings struct {
+	URI           string
+	Provider      string
+	ResourceGroup string
+	AccountName   string
+	Subscription  string
+	AzureClient   string
+	ARMEndpoint   string
+	Dir           string
+	Timeout       time.Duration
+	AzTimeout     time.Duration
+	LockTTL       time.Duration
+}
+
+// resolveSettings reads the config file selected by --config and --env, and applies flags over it.
+func resolveSettings(c *cli.Context) (*settings, error) {
+	env := &environment{}
+
+	name, err := findConfig(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		cfg, err := loadConfig(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if env, err = cfg.environment(c.String("env")); err != nil {
+			return nil, err
+		}
+	}
+
+	uri, err := env.uri()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if v := os.Getenv("URI"); v != "" {
+		uri = v
+	}
+
+	return &settings{
+		URI:           uri,
+		Provider:      stringSetting(c, "provider", env.Provider),
+		ResourceGroup: stringSetting(c, "rg", env.ResourceGroup),
+		AccountName:   stringSetting(c, "account-name", env.AccountName),
+		Subscription:  stringSetting(c, "subscription", env.Subscription),
+		AzureClient:   stringSetting(c, "azure-client", env.AzureClient),
+		ARMEndpoint:   stringSetting(c, "arm-endpoint", env.ARMEndpoint),
+		Dir:           stringSetting(c, "dir", env.Dir),
+		Timeout:       durationSetting(c, "timeout", env.Timeout),
+		AzTimeout:     durationSetting(c, "az-timeout", env.AzTimeout),
+		LockTTL:       durationSetting(c, "lock-ttl", env.LockTTL),
+	}, nil
+}
+
+// uri returns the connection string given either directly, by environment variable or by file.
+func (env *environment) uri() (string, error) {
+	switch {
+	case env.URI != "":
+		return env.URI, nil
+	case env.URIEnv != "":
+		return os.Getenv(env.URIEnv), nil
+	case env.URIFile != "":
+		b, err := os.ReadFile(env.URIFile)
+
+		if err != nil {
+			return "", fmt.Errorf("failed to read uriFile, %s", err)
+		}
+
+		return strings.TrimSpace(string(b)), nil
+	default:
+		return "", nil
+	}
+}
+
+// stringSetting returns the flag when set explicitly or by its environment variable,
+// the value of the config file when given, and the default of the flag otherwise.
+func stringSetting(c *cli.Context, flag string, file string) string {
+	if c.IsSet(flag) || file == "" {
+		return c.String(flag)
+	}
+
+	return file
+}
+
+// durationSetting is stringSetting for durations.
+func durationSetting(c *cli.Context, flag string, file duration) time.Duration {
+	if c.IsSet(flag) || file == 0 {
+		return c.Duration(flag)
+	}
+
+	return time.Duration(file)
+}
@@ -1,26 +1,148 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	cli "github.com/urfave/cli/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"sios.tech/covas/migrate/migration"
+)
+
+// Values of --azure-client.
+const (
+	azureClientAz  = "az"
+	azureClientARM = "arm"
 )
 
+// exitInterrupted is the exit code when migration is stopped by SIGINT or SIGTERM,
+// so that callers can tell it from a failure.
+const exitInterrupted = 130
+
 func main() {
+	console := &output{w: os.Stdout}
+
 	app := &cli.App{
 		Name:    "migrate",
 		Usage:   "MongoDB migration tool with minimal api",
-		Version: "0.7.0",
+		Version: migration.Version,
+		Before: func(c *cli.Context) error {
+			if err := console.setFormat(c.String("output")); err != nil {
+				console.Failed(err)
+				return err
+			}
+
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				EnvVars: []string{"MIGRATE_CONFIG"},
+				Usage:   "Config file defining environments, migrate.yaml, migrate.yml or migrate.json by default",
+			},
+			&cli.StringFlag{
+				Name:    "env",
+				Aliases: []string{"e"},
+				EnvVars: []string{"MIGRATE_ENV"},
+				Usage:   "Environment of the config file, default of the config file when omitted",
+			},
+			&cli.StringFlag{
+				Name:    "account-name",
+				EnvVars: []string{"MIGRATE_ACCOUNT_NAME"},
+				Usage:   "Cosmos DB account name, username of URI when omitted",
+			},
+			&cli.StringFlag{
+				Name:    "subscription",
+				EnvVars: []string{"MIGRATE_SUBSCRIPTION"},
+				Usage:   "Azure subscription passed to az, default subscription of az when omitted",
+			},
+			&cli.StringFlag{
+				Name:    "provider",
+				EnvVars: []string{"MIGRATE_PROVIDER"},
+				Usage:   "How admin commands run, local, cosmos-ru, cosmos-vcore or sharded. local for localhost and cosmos-ru otherwise when omitted",
+			},
+			&cli.StringFlag{
+				Name:    "azure-client",
+				EnvVars: []string{"MIGRATE_AZURE_CLIENT"},
+				Value:   azureClientAz,
+				Usage:   "How cosmos-ru runs admin commands, az or arm which calls Azure Resource Manager without az",
+			},
+			&cli.StringFlag{
+				Name:    "arm-endpoint",
+				EnvVars: []string{"MIGRATE_ARM_ENDPOINT"},
+				Value:   migration.DefaultARMEndpoint,
+				Usage:   "Endpoint of Azure Resource Manager used by --azure-client arm",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Value: outputText,
+				Usage: "Output format, text or json which prints one event per line",
+			},
+			&cli.DurationFlag{
+				Name:    "timeout",
+				EnvVars: []string{"MIGRATE_TIMEOUT"},
+				Value:   30 * time.Second,
+				Usage:   "Timeout of each command, timeout of the file takes precedence",
+			},
+			&cli.DurationFlag{
+				Name:    "az-timeout",
+				EnvVars: []string{"MIGRATE_AZ_TIMEOUT"},
+				Value:   10 * time.Minute,
+				Usage:   "Timeout of each az process, timeout of the file takes precedence",
+			},
+			&cli.IntFlag{
+				Name:  "max-retries",
+				Value: 5,
+				Usage: "Number of retries when throttled by Cosmos DB, negative to disable",
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "init",
 				Usage: "Setup",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						EnvVars: []string{"MIGRATE_DIR"},
+						Value:   "migrations",
+						Usage:   "Directory, zip or tar archive of your JSON files, the first file may create the database",
+					},
+					&cli.StringFlag{
+						Name:    "rg",
+						Aliases: []string{"r"},
+						EnvVars: []string{"MIGRATE_RESOURCE_GROUP"},
+						Value:   "MyResourceGroup",
+						Usage:   "Resource Group Name",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					out := Setup()
-					fmt.Println(out)
-					return out
+					m, disconnect, err := connect(c, console, c.String("dir"))
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
+
+					if err := m.Init(c.Context); err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					console.Done()
+					return nil
 				},
 			},
 			{
@@ -30,59 +152,122 @@ func main() {
 					&cli.StringFlag{
 						Name:    "dir",
 						Aliases: []string{"d"},
+						EnvVars: []string{"MIGRATE_DIR"},
 						Value:   "migrations",
-						Usage:   "Directory of your JSON files",
+						Usage:   "Directory, zip or tar archive of your JSON files",
 					},
 					&cli.StringFlag{
 						Name:    "rg",
 						Aliases: []string{"r"},
+						EnvVars: []string{"MIGRATE_RESOURCE_GROUP"},
 						Value:   "MyResourceGroup",
 						Usage:   "Resource Group Name",
 					},
+					&cli.DurationFlag{
+						Name:  "lock-ttl",
+						Value: 2 * time.Minute,
+						Usage: "Lifetime of the lock, extended while running",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Stop after the given file has been applied",
+					},
+					&cli.IntFlag{
+						Name:  "steps",
+						Usage: "Stop after the given number of files have been applied",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-out-of-order",
+						Usage: "Apply files sorting before current version which have never been applied",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 2 * time.Second,
+						Usage: "Wait between files to reduce database load, 0 to disable",
+					},
+					&cli.StringFlag{
+						Name:  "pacing",
+						Value: string(migration.PacingFixed),
+						Usage: "fixed waits interval after every file, adaptive waits only after index builds, admin commands and throttling",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print what would be applied without changing anything",
+					},
 				},
 				Action: func(c *cli.Context) error {
-					for {
-						fmt.Printf("checking current state.. ")
-						cur, err := Current()
-
-						if err != nil {
-							fmt.Printf("failed, %s \n", err)
-							return err
-						}
-
-						fmt.Printf("ok \n")
-
-						fmt.Printf("retrieving the changes.. ")
-
-						next, err := Next(c.String("dir"), cur)
-
-						if err != nil {
-							fmt.Printf("failed, %s \n", err)
-							return err
-						}
+					opts := migration.UpOptions{
+						To:              c.String("to"),
+						Steps:           c.Int("steps"),
+						AllowOutOfOrder: c.Bool("allow-out-of-order"),
+					}
 
-						if next == nil {
-							fmt.Printf("no more migrations. \n")
-							break
-						}
+					m, disconnect, err := connect(c, console, c.String("dir"))
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
 
-						fmt.Printf("%s \n", next.Version)
-						fmt.Printf("applying changes.. ")
+					if c.Bool("dry-run") {
+						return dryRunUp(c.Context, console, m, opts)
+					}
 
-						resourceGroup := c.String("rg")
-						u, _ := ParseURI(os.Getenv("URI"))
-						if err := Apply(next, u, resourceGroup); err != nil {
-							fmt.Printf("failed, %s \n", err)
-							return err
-						}
+					if err := m.Up(c.Context, opts); err != nil {
+						console.Failed(err)
+						return err
+					}
 
-						fmt.Printf("completed migration. \n")
+					console.Done()
+					return nil
+				},
+			},
+			{
+				Name:  "down",
+				Usage: "Roll back migration",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						EnvVars: []string{"MIGRATE_DIR"},
+						Value:   "migrations",
+						Usage:   "Directory, zip or tar archive of your JSON files",
+					},
+					&cli.StringFlag{
+						Name:    "rg",
+						Aliases: []string{"r"},
+						EnvVars: []string{"MIGRATE_RESOURCE_GROUP"},
+						Value:   "MyResourceGroup",
+						Usage:   "Resource Group Name",
+					},
+					&cli.IntFlag{
+						Name:  "steps",
+						Usage: "Number of files to roll back, 1 when neither steps nor to is given",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Roll back until the given file becomes current, 0 to roll back everything",
+					},
+					&cli.DurationFlag{
+						Name:  "lock-ttl",
+						Value: 2 * time.Minute,
+						Usage: "Lifetime of the lock, extended while running",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					m, disconnect, err := connect(c, console, c.String("dir"))
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
 
-						// Set interval to reduce database load.
-						time.Sleep(2 * time.Second)
+					if err := m.Down(c.Context, c.Int("steps"), c.String("to")); err != nil {
+						console.Failed(err)
+						return err
 					}
 
-					fmt.Println("done!")
+					console.Done()
 					return nil
 				},
 			},
@@ -105,22 +290,49 @@ func main() {
 					&cli.StringFlag{
 						Name:    "rg",
 						Aliases: []string{"r"},
+						EnvVars: []string{"MIGRATE_RESOURCE_GROUP"},
 						Value:   "MyResourceGroup",
 						Usage:   "Resource Group Name",
 					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print what would be run without changing anything",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					file := c.String("file")
-					adminFlag := c.String("admin")
-					resourceGroup := c.String("rg")
-					u, _ := ParseURI(os.Getenv("URI"))
-					if err := Update(file, adminFlag, u, resourceGroup); err != nil {
-						fmt.Printf("failed, %s \n", err)
+					admin := c.String("admin") == "true"
+
+					m, disconnect, err := connect(c, console, filepath.Dir(file))
+					if err != nil {
+						console.Failed(err)
 						return err
 					}
+					defer disconnect()
+
+					if c.Bool("dry-run") {
+						steps, err := m.PlanFix(filepath.Base(file), admin)
+						if err != nil {
+							console.Failed(err)
+							return err
+						}
 
-					fmt.Println(file)
-					fmt.Println("done!")
+						console.Printf("%s", file)
+						for _, s := range steps {
+							console.Printf("  %s \n", s)
+						}
+
+						console.Printf("dry run, nothing has been run.")
+						return nil
+					}
+
+					if err := m.Fix(c.Context, filepath.Base(file), admin); err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					console.Printf("%s", file)
+					console.Done()
 					return nil
 				},
 			},
@@ -137,13 +349,143 @@ func main() {
 				},
 				Action: func(c *cli.Context) error {
 					fileName := c.String("name")
-					if err := Revert(fileName); err != nil {
-						fmt.Printf("failed, %s \n", err)
+
+					m, disconnect, err := connect(c, console, "")
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
+
+					if err := m.Revert(c.Context, fileName); err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					console.Printf("%s", fileName)
+					console.Done()
+					return nil
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "Show applied, pending and missing migrations",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						EnvVars: []string{"MIGRATE_DIR"},
+						Value:   "migrations",
+						Usage:   "Directory, zip or tar archive of your JSON files",
+					},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Value:   "table",
+						Usage:   "Output format, table or json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					m, disconnect, err := connect(c, console, c.String("dir"))
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
+
+					report, err := m.Status(c.Context)
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					// Every line is an event on JSON output, including the report and its warnings.
+					if console.json {
+						console.Status(report)
+
+						for _, v := range report.Modified() {
+							console.Event(migration.Event{Phase: migration.PhaseModified, Version: v})
+						}
+
+						return nil
+					}
+
+					if err := report.Write(os.Stdout, c.String("format")); err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					for _, v := range report.Modified() {
+						fmt.Fprintf(os.Stderr, "warning: %s has changed since applied \n", v)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "repair",
+				Usage: "Accept changed checksum of applied files",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						EnvVars: []string{"MIGRATE_DIR"},
+						Value:   "migrations",
+						Usage:   "Directory, zip or tar archive of your JSON files",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					m, disconnect, err := connect(c, console, c.String("dir"))
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
+
+					drifts, err := m.Repair(c.Context)
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					for _, d := range drifts {
+						console.Printf("%s: %s -> %s \n", d.Version, d.Recorded, d.Actual)
+					}
+
+					console.Done()
+					return nil
+				},
+			},
+			{
+				Name:  "unlock",
+				Usage: "Remove the lock left by a stopped migration",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Remove the lock even if it has not expired yet",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					m, disconnect, err := connect(c, console, "")
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
+
+					held, err := m.Unlock(c.Context, c.Bool("force"))
+					if err != nil {
+						console.Failed(err)
 						return err
 					}
 
-					fmt.Println(fileName)
-					fmt.Println("done!")
+					if held == nil {
+						console.Printf("not locked")
+						return nil
+					}
+
+					console.Printf("removed lock held by %s \n", held.Owner)
+					console.Done()
 					return nil
 				},
 			},
@@ -162,17 +504,32 @@ func main() {
 					collectionName := c.String("name")
 
 					if collectionName == "" {
-						fmt.Println("required name option")
+						console.Printf("required name option")
 						return nil
 					}
 
-					if err := FindIndex(collectionName); err != nil {
-						fmt.Printf("failed, %s \n", err)
+					m, disconnect, err := connect(c, console, "")
+					if err != nil {
+						console.Failed(err)
 						return err
 					}
+					defer disconnect()
 
-					fmt.Println(collectionName)
-					fmt.Println("done!")
+					indexes, err := m.Indexes(c.Context, collectionName)
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+
+					for _, v := range indexes {
+						for k1, v1 := range v {
+							console.Printf("%v: %v\n", k1, v1)
+						}
+						console.Printf("")
+					}
+
+					console.Printf("%s", collectionName)
+					console.Done()
 					return nil
 				},
 			},
@@ -198,26 +555,245 @@ func main() {
 					indexName := c.String("index")
 
 					if collectionName == "" || indexName == "" {
-						fmt.Println("required collection and index option")
+						console.Printf("required collection and index option")
 						return nil
 					}
 
-					if err := DeleteIndex(collectionName, indexName); err != nil {
-						fmt.Printf("failed, %s \n", err)
+					m, disconnect, err := connect(c, console, "")
+					if err != nil {
+						console.Failed(err)
+						return err
+					}
+					defer disconnect()
+
+					if err := m.DeleteIndex(c.Context, collectionName, indexName); err != nil {
+						console.Failed(err)
 						return err
 					}
 
-					fmt.Printf("collection: %s, index: %s \n", collectionName, indexName)
-					fmt.Println("done!")
+					console.Printf("collection: %s, index: %s \n", collectionName, indexName)
+					console.Done()
 					return nil
 				},
 			},
 		},
 	}
 
-	err := app.Run(os.Args)
+	err := app.RunContext(signalContext(console), os.Args)
+	if errors.Is(err, migration.ErrInterrupted) {
+		os.Exit(exitInterrupted)
+	}
 	if err != nil {
 		// エラーがある場合は異常終了にしたい
 		os.Exit(1)
 	}
 }
+
+// signalContext is cancelled on the first SIGINT or SIGTERM, so the running step can complete.
+// The second signal terminates the process immediately.
+func signalContext(console *output) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		s := <-sig
+		console.Printf("received %s, stopping after the current step. send it again to abort. \n", s)
+		signal.Stop(sig)
+		cancel()
+	}()
+
+	return ctx
+}
+
+// connect builds Migrator reading JSON files from dir, with settings resolved from flags,
+// environment variables and the config file.
+// dir is either a directory or a zip or tar archive, and can be empty when the command reads no file.
+func connect(c *cli.Context, console *output, dir string) (*migration.Migrator, func(), error) {
+	conf, err := resolveSettings(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// dir of --dir is taken from the config file unless given, while fix gives its own.
+	if dir != "" && dir == c.String("dir") {
+		dir = conf.Dir
+	}
+
+	u, err := ParseURI(conf.URI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("incorrect URI given, %s", err)
+	}
+
+	accountName := conf.AccountName
+	if accountName == "" {
+		accountName = u.Username
+	}
+
+	executor, err := azureExecutor(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fsys fs.FS
+	closeSource := func() error { return nil }
+
+	if dir != "" {
+		fsys, closeSource, err = openSource(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	started := time.Now()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(conf.URI))
+	if err != nil {
+		closeSource()
+		return nil, nil, err
+	}
+
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		closeSource()
+		return nil, nil, err
+	}
+
+	console.Event(migration.Event{Phase: migration.PhaseConnect, DurationMs: time.Since(started).Milliseconds()})
+
+	// Zero means no wait on the command line, while Options takes it as default.
+	interval := c.Duration("interval")
+	if interval == 0 {
+		interval = -1
+	}
+
+	m := migration.New(client, u.Database, migration.Options{
+		Dir:           ".",
+		FS:            fsys,
+		ResourceGroup: conf.ResourceGroup,
+		AccountName:   accountName,
+		Subscription:  conf.Subscription,
+		Executor:      executor,
+		Provider:      provider(conf.Provider, u),
+		LockTTL:       conf.LockTTL,
+		Timeout:       conf.Timeout,
+		AzTimeout:     conf.AzTimeout,
+		Retry:         migration.Retry{MaxRetries: c.Int("max-retries")},
+		Interval:      interval,
+		Pacing:        migration.Pacing(c.String("pacing")),
+		Logger:        console,
+		OnEvent:       console.Event,
+	})
+
+	disconnect := func() {
+		if err := client.Disconnect(context.Background()); err != nil {
+			console.Failed(err)
+		}
+
+		if err := closeSource(); err != nil {
+			console.Failed(err)
+		}
+	}
+
+	return m, disconnect, nil
+}
+
+// azureExecutor returns how admin commands of cosmos-ru run, by --azure-client.
+func azureExecutor(conf *settings) (migration.Executor, error) {
+	switch conf.AzureClient {
+	case azureClientAz:
+		return migration.AzCLI{}, nil
+	case azureClientARM:
+		subscription := conf.Subscription
+		if subscription == "" {
+			subscription = os.Getenv("AZURE_SUBSCRIPTION_ID")
+		}
+
+		// Token requests go through the same client as Azure Resource Manager.
+		client := http.DefaultClient
+
+		return &migration.ARMClient{
+			BaseURL:      conf.ARMEndpoint,
+			Subscription: subscription,
+			HTTPClient:   client,
+			Token:        migration.EnvironmentToken(os.Getenv("AZURE_AUTHORITY_HOST"), conf.ARMEndpoint, client),
+		}, nil
+	default:
+		return nil, fmt.Errorf("azure-client must be either %s or %s", azureClientAz, azureClientARM)
+	}
+}
+
+// provider returns the given provider, or guesses it from host of URI for compatibility.
+func provider(given string, u *URI) migration.Provider {
+	if given != "" {
+		return migration.Provider(given)
+	}
+
+	host := u.Host
+
+	if h, _, err := net.SplitHostPort(u.Host); err == nil {
+		host = h
+	}
+
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return migration.ProviderLocal
+	}
+
+	return migration.ProviderCosmosRU
+}
+
+// dryRunUp prints what `up` would do, without taking the lock nor changing the pointer.
+func dryRunUp(ctx context.Context, console *output, m *migration.Migrator, opts migration.UpOptions) error {
+	drifts, err := m.Verify(ctx)
+	if err != nil {
+		console.Failed(err)
+		return err
+	}
+
+	for _, d := range drifts {
+		console.Printf("warning: %s has changed since applied, up would stop here \n", d.Version)
+	}
+
+	skipped, err := m.Skipped(ctx)
+	if err != nil {
+		console.Failed(err)
+		return err
+	}
+
+	if len(skipped) > 0 && !opts.AllowOutOfOrder {
+		for _, s := range skipped {
+			console.Printf("warning: %s has never been applied, up would stop here without --allow-out-of-order \n", s.Version)
+		}
+		skipped = nil
+	}
+
+	pending, err := m.PlanUp(ctx, opts)
+	if err != nil {
+		console.Failed(err)
+		return err
+	}
+
+	for _, cmd := range append(skipped, pending...) {
+		console.Printf("%s \n", cmd.Version)
+
+		planned, err := m.PlanCommand(cmd, true)
+		if err != nil {
+			console.Failed(err)
+			return err
+		}
+
+		for _, s := range planned {
+			console.Printf("  %s \n", s)
+		}
+	}
+
+	if len(skipped)+len(pending) == 0 {
+		console.Printf("no more migrations. \n")
+	}
+
+	console.Printf("dry run, nothing has been applied.")
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"sios.tech/covas/migrate/migration"
+)
+
+func TestProvider(t *testing.T) {
+	type pattern struct {
+		given string
+		host  string
+		exp   migration.Provider
+	}
+
+	pats := []pattern{
+		{"", "localhost:30000", migration.ProviderLocal},
+		{"", "127.0.0.1:30000", migration.ProviderLocal},
+		{"", "[::1]:30000", migration.ProviderLocal},
+		{"", "account.mongo.cosmos.azure.com:10255", migration.ProviderCosmosRU},
+		{"sharded", "mongos:27017", migration.ProviderSharded},
+		{"cosmos-ru", "localhost:30000", migration.ProviderCosmosRU},
+	}
+
+	for idx, p := range pats {
+		if got := provider(p.given, &URI{Host: p.host}); got != p.exp {
+			t.Errorf("case %d expected %s, got %s", idx, p.exp, got)
+		}
+	}
+}
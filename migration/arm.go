@@ -0,0 +1,655 @@
+package migration
+
+// az を使わずに Azure Resource Manager の REST API を直接呼び出す
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultARMEndpoint is the endpoint of Azure Resource Manager on the public cloud.
+	DefaultARMEndpoint = "https://management.azure.com"
+	// armAPIVersion is the version of Microsoft.DocumentDB resource provider.
+	armAPIVersion = "2023-11-15"
+)
+
+// ARMClient is Executor which runs arguments built by AzureCommand.CreateCommand against Azure Resource Manager,
+// so admin commands run without az installed.
+type ARMClient struct {
+	// BaseURL is the endpoint of Azure Resource Manager, DefaultARMEndpoint by default.
+	BaseURL string
+	// Subscription is used when arguments do not contain --subscription.
+	Subscription string
+	// Token returns bearer token for Azure Resource Manager.
+	Token func(ctx context.Context) (string, error)
+	// HTTPClient sends requests, http.DefaultClient by default.
+	HTTPClient *http.Client
+	// PollInterval is the wait between polls of long running operations unless Retry-After is given,
+	// 5 seconds by default.
+	PollInterval time.Duration
+}
+
+// ARMError represents error response of Azure Resource Manager.
+type ARMError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// RetryAfter is given by Retry-After header on throttling.
+	RetryAfter time.Duration
+}
+
+func (e *ARMError) Error() string {
+	return fmt.Sprintf("azure resource manager returned %d %s, %s", e.StatusCode, e.Code, e.Message)
+}
+
+// armArgs is az arguments split into the command and its flags.
+type armArgs struct {
+	// words are the command such as "cosmosdb mongodb collection throughput show".
+	words []string
+	flags map[string]string
+}
+
+// armFlags maps short flags of az to long ones.
+var armFlags = map[string]string{
+	"-g": "--resource-group",
+	"-a": "--account-name",
+	"-d": "--database-name",
+	"-n": "--name",
+}
+
+// armSwitches are flags of az without value.
+var armSwitches = map[string]bool{
+	"--yes": true,
+	"-y":    true,
+}
+
+func parseARMArgs(args []string) (armArgs, error) {
+	out := armArgs{flags: map[string]string{}}
+
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+
+		if !strings.HasPrefix(a, "-") {
+			if len(out.flags) > 0 {
+				return out, fmt.Errorf("unexpected argument %s", a)
+			}
+
+			out.words = append(out.words, a)
+			continue
+		}
+
+		if armSwitches[a] {
+			continue
+		}
+
+		if long, ok := armFlags[a]; ok {
+			a = long
+		}
+
+		if i+1 >= len(args) {
+			return out, fmt.Errorf("flag %s requires value", a)
+		}
+
+		out.flags[a] = args[i+1]
+		i++
+	}
+
+	return out, nil
+}
+
+// require returns the value of the flag, or error when it is not given.
+func (a armArgs) require(flag string) (string, error) {
+	v := a.flags[flag]
+
+	if v == "" {
+		return "", fmt.Errorf("%s is required", flag)
+	}
+
+	return v, nil
+}
+
+/*
+Execute runs the given az arguments, and returns the resource as az prints it.
+Supported are create, delete, show, exists, list and throughput show / update / migrate of
+collections and databases under `cosmosdb mongodb`.
+
+az の引数に相当する操作を Azure Resource Manager の REST API で実行します。
+*/
+func (c *ARMClient) Execute(ctx context.Context, args []string) ([]byte, error) {
+	parsed, err := parseARMArgs(args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := strings.Join(parsed.words, " ")
+
+	kind, ok := strings.CutPrefix(cmd, "cosmosdb mongodb ")
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported command %s", cmd)
+	}
+
+	kind, verb, _ := strings.Cut(kind, " ")
+
+	var resource string
+
+	switch kind {
+	case "collection":
+		resource, err = c.collectionPath(parsed, verb == "list")
+	case "database":
+		resource, err = c.databasePath(parsed, verb == "list")
+	default:
+		return nil, fmt.Errorf("unsupported command %s", cmd)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch verb {
+	case "create":
+		body, err := createBody(kind, parsed)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return flatten(c.put(ctx, resource, body))
+	case "delete":
+		return c.delete(ctx, resource)
+	case "show":
+		return flatten(c.do(ctx, http.MethodGet, resource, nil))
+	case "list":
+		return flattenList(c.do(ctx, http.MethodGet, resource, nil))
+	case "exists":
+		_, err := c.do(ctx, http.MethodGet, resource, nil)
+
+		var ae *ARMError
+
+		if errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound {
+			return []byte("false"), nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte("true"), nil
+	case "throughput show":
+		return flatten(c.do(ctx, http.MethodGet, resource+"/throughputSettings/default", nil))
+	case "throughput update":
+		body, err := throughputBody(parsed)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return flatten(c.put(ctx, resource+"/throughputSettings/default", body))
+	case "throughput migrate":
+		op, err := migrateOperation(parsed)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := c.do(ctx, http.MethodPost, resource+"/throughputSettings/default/"+op, nil); err != nil {
+			return nil, err
+		}
+
+		return flatten(c.do(ctx, http.MethodGet, resource+"/throughputSettings/default", nil))
+	default:
+		return nil, fmt.Errorf("unsupported command %s", cmd)
+	}
+}
+
+// flatten moves fields of "properties" to the top level as az prints resources.
+func flatten(out []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+
+	if err := json.Unmarshal(out, &fields); err != nil {
+		return nil, fmt.Errorf("incorrect response, %s", err)
+	}
+
+	props, ok := fields["properties"]
+
+	if !ok {
+		return out, nil
+	}
+
+	delete(fields, "properties")
+
+	var inner map[string]json.RawMessage
+
+	if err := json.Unmarshal(props, &inner); err != nil {
+		return nil, fmt.Errorf("incorrect response, %s", err)
+	}
+
+	for k, v := range inner {
+		fields[k] = v
+	}
+
+	return json.Marshal(fields)
+}
+
+// flattenList returns "value" of the list with each resource flattened, as az prints lists.
+func flattenList(out []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Value []json.RawMessage `json:"value"`
+	}
+
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("incorrect response, %s", err)
+	}
+
+	items := make([]json.RawMessage, 0, len(list.Value))
+
+	for _, v := range list.Value {
+		item, err := flatten(v, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return json.Marshal(items)
+}
+
+func (c *ARMClient) accountPath(a armArgs) (string, error) {
+	sub := a.flags["--subscription"]
+
+	if sub == "" {
+		sub = c.Subscription
+	}
+
+	if sub == "" {
+		return "", fmt.Errorf("subscription is required to call azure resource manager")
+	}
+
+	rg, err := a.require("--resource-group")
+
+	if err != nil {
+		return "", err
+	}
+
+	account, err := a.require("--account-name")
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DocumentDB/databaseAccounts/%s",
+		url.PathEscape(sub), url.PathEscape(rg), url.PathEscape(account)), nil
+}
+
+func (c *ARMClient) databasePath(a armArgs, list bool) (string, error) {
+	account, err := c.accountPath(a)
+
+	if err != nil {
+		return "", err
+	}
+
+	if list {
+		return account + "/mongodbDatabases", nil
+	}
+
+	name, err := a.require("--name")
+
+	if err != nil {
+		return "", err
+	}
+
+	return account + "/mongodbDatabases/" + url.PathEscape(name), nil
+}
+
+func (c *ARMClient) collectionPath(a armArgs, list bool) (string, error) {
+	account, err := c.accountPath(a)
+
+	if err != nil {
+		return "", err
+	}
+
+	db, err := a.require("--database-name")
+
+	if err != nil {
+		return "", err
+	}
+
+	database := account + "/mongodbDatabases/" + url.PathEscape(db) + "/collections"
+
+	if list {
+		return database, nil
+	}
+
+	name, err := a.require("--name")
+
+	if err != nil {
+		return "", err
+	}
+
+	return database + "/" + url.PathEscape(name), nil
+}
+
+// createBody builds the resource to PUT, shard key is hashed as az does.
+func createBody(kind string, a armArgs) (any, error) {
+	resource := map[string]any{"id": a.flags["--name"]}
+
+	if kind == "collection" {
+		if shard := a.flags["--shard"]; shard != "" {
+			resource["shardKey"] = map[string]string{shard: "Hash"}
+		}
+	}
+
+	options, err := throughputSettings(a)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"properties": map[string]any{"resource": resource, "options": options}}, nil
+}
+
+// throughputBody builds throughput settings to PUT, either manual or autoscale.
+func throughputBody(a armArgs) (any, error) {
+	resource, err := throughputSettings(a)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resource) != 1 {
+		return nil, fmt.Errorf("either --throughput or --max-throughput is required")
+	}
+
+	return map[string]any{"properties": map[string]any{"resource": resource}}, nil
+}
+
+// migrateOperation returns the action to switch throughput to --throughput-type.
+func migrateOperation(a armArgs) (string, error) {
+	switch t := a.flags["--throughput-type"]; t {
+	case "autoscale":
+		return "migrateToAutoscale", nil
+	case "manual":
+		return "migrateToManualThroughput", nil
+	default:
+		return "", fmt.Errorf("incorrect throughput type %s", t)
+	}
+}
+
+// throughputSettings reads --throughput as manual and --max-throughput as autoscale throughput.
+func throughputSettings(a armArgs) (map[string]any, error) {
+	out := map[string]any{}
+
+	if v, ok := a.flags["--throughput"]; ok {
+		n, err := strconv.Atoi(v)
+
+		if err != nil {
+			return nil, fmt.Errorf("incorrect throughput %s, %s", v, err)
+		}
+
+		out["throughput"] = n
+	}
+
+	if v, ok := a.flags["--max-throughput"]; ok {
+		n, err := strconv.Atoi(v)
+
+		if err != nil {
+			return nil, fmt.Errorf("incorrect max throughput %s, %s", v, err)
+		}
+
+		out["autoscaleSettings"] = map[string]int{"maxThroughput": n}
+	}
+
+	return out, nil
+}
+
+// put creates or updates the resource, and returns it once the operation has completed.
+func (c *ARMClient) put(ctx context.Context, resource string, body any) ([]byte, error) {
+	if _, err := c.do(ctx, http.MethodPut, resource, body); err != nil {
+		return nil, err
+	}
+
+	return c.do(ctx, http.MethodGet, resource, nil)
+}
+
+func (c *ARMClient) delete(ctx context.Context, resource string) ([]byte, error) {
+	if _, err := c.do(ctx, http.MethodDelete, resource, nil); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// do sends the request, and waits for the long running operation when accepted.
+func (c *ARMClient) do(ctx context.Context, method, resource string, body any) ([]byte, error) {
+	base := c.BaseURL
+
+	if base == "" {
+		base = DefaultARMEndpoint
+	}
+
+	target := fmt.Sprintf("%s%s?api-version=%s", strings.TrimSuffix(base, "/"), resource, armAPIVersion)
+
+	res, out, err := c.send(ctx, method, target, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusAccepted && res.StatusCode != http.StatusCreated {
+		return out, nil
+	}
+
+	if op := res.Header.Get("Azure-AsyncOperation"); op != "" {
+		return out, c.poll(ctx, op, retryAfter(res, c.pollInterval()))
+	}
+
+	if loc := res.Header.Get("Location"); loc != "" && res.StatusCode == http.StatusAccepted {
+		return out, c.pollLocation(ctx, loc, retryAfter(res, c.pollInterval()))
+	}
+
+	return out, nil
+}
+
+// poll waits for Azure-AsyncOperation to reach a terminal status.
+func (c *ARMClient) poll(ctx context.Context, op string, wait time.Duration) error {
+	for {
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+
+		res, out, err := c.send(ctx, http.MethodGet, op, nil)
+
+		if err != nil {
+			return err
+		}
+
+		var status struct {
+			Status string `json:"status"`
+			Error  struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		if err := json.Unmarshal(out, &status); err != nil {
+			return fmt.Errorf("incorrect operation status, %s", err)
+		}
+
+		switch status.Status {
+		case "Succeeded":
+			return nil
+		case "Failed", "Canceled", "Cancelled":
+			return &ARMError{StatusCode: res.StatusCode, Code: status.Error.Code, Message: fmt.Sprintf("operation %s, %s", strings.ToLower(status.Status), status.Error.Message)}
+		}
+
+		wait = retryAfter(res, c.pollInterval())
+	}
+}
+
+// pollLocation waits until Location stops returning 202.
+func (c *ARMClient) pollLocation(ctx context.Context, loc string, wait time.Duration) error {
+	for {
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+
+		res, _, err := c.send(ctx, http.MethodGet, loc, nil)
+
+		if err != nil {
+			return err
+		}
+
+		if res.StatusCode != http.StatusAccepted {
+			return nil
+		}
+
+		wait = retryAfter(res, c.pollInterval())
+	}
+}
+
+// send sends a single request, and converts error responses into ARMError.
+func (c *ARMClient) send(ctx context.Context, method, target string, body any) (*http.Response, []byte, error) {
+	var reader io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.Token != nil {
+		token, err := c.Token(ctx)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get token, %s", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := c.HTTPClient
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer res.Body.Close()
+
+	out, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		return nil, nil, armError(res, out)
+	}
+
+	return res, out, nil
+}
+
+func armError(res *http.Response, body []byte) *ARMError {
+	e := &ARMError{StatusCode: res.StatusCode, RetryAfter: retryAfter(res, 0)}
+
+	// Microsoft.DocumentDB returns either {"error": {...}} or {"code": ..., "message": ...}.
+	var parsed struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Error   *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		e.Code, e.Message = parsed.Code, parsed.Message
+
+		if parsed.Error != nil {
+			e.Code, e.Message = parsed.Error.Code, parsed.Error.Message
+		}
+	}
+
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	if e.Code == "" {
+		e.Code = strings.ReplaceAll(http.StatusText(res.StatusCode), " ", "")
+	}
+
+	return e
+}
+
+func (c *ARMClient) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+
+	return 5 * time.Second
+}
+
+// retryAfter reads Retry-After header either in seconds or as HTTP-date, def is returned when it is not given.
+func retryAfter(res *http.Response, def time.Duration) time.Duration {
+	v := res.Header.Get("Retry-After")
+
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		// A date already passed means retrying right away.
+		return max(time.Until(at), 0)
+	}
+
+	return def
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
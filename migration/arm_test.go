@@ -0,0 +1,371 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAccountPath = "/subscriptions/sub/resourceGroups/develop/providers/Microsoft.DocumentDB/databaseAccounts/account"
+
+// armStandIn serves Azure Resource Manager in memory, PUT completes through Azure-AsyncOperation.
+type armStandIn struct {
+	mu        sync.Mutex
+	resources map[string]json.RawMessage
+	requests  []string
+	bodies    map[string]string
+}
+
+func newARMStandIn(t *testing.T) (*armStandIn, *ARMClient) {
+	s := &armStandIn{resources: map[string]json.RawMessage{}, bodies: map[string]string{}}
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	client := &ARMClient{
+		BaseURL:      srv.URL,
+		Subscription: "sub",
+		Token:        func(context.Context) (string, error) { return "token", nil },
+		PollInterval: time.Millisecond,
+	}
+
+	return s, client
+}
+
+func (s *armStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/operations/2" {
+		return
+	}
+
+	if r.URL.Path == "/operations/1" {
+		io.WriteString(w, `{"status": "Succeeded"}`)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		s.bodies[r.URL.Path] = string(b)
+		s.resources[r.URL.Path] = b
+
+		w.Header().Set("Azure-AsyncOperation", "http://"+r.Host+"/operations/1")
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodGet:
+		res, ok := s.resources[r.URL.Path]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"code": "NotFound", "message": "Resource not found."}`)
+			return
+		}
+
+		w.Write(res)
+	case http.MethodPost:
+		// Switching throughput keeps the value, which is updated separately.
+		settings, op := path.Split(r.URL.Path)
+		settings = strings.TrimSuffix(settings, "/")
+
+		if op == "migrateToAutoscale" {
+			s.resources[settings] = json.RawMessage(`{"properties":{"resource":{"autoscaleSettings":{"maxThroughput":4000}}}}`)
+		} else {
+			s.resources[settings] = json.RawMessage(`{"properties":{"resource":{"throughput":400}}}`)
+		}
+
+		w.Header().Set("Location", "http://"+r.Host+"/operations/2")
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		delete(s.resources, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestARMClientExecute(t *testing.T) {
+	ctx := context.Background()
+	s, client := newARMStandIn(t)
+
+	users := testAccountPath + "/mongodbDatabases/demo/collections/users"
+
+	// OK on create after polling the operation.
+	func() {
+		throughput := 4000
+		autoScale := true
+		cmd := AzureCommand{Collection: "users", ShardKey: "_id", AutoScale: &autoScale, Throughput: &throughput}
+
+		args, err := cmd.CreateCommand(Create, "develop", "account", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		out, err := client.Execute(ctx, args)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		exp := `{"properties":{"options":{"autoscaleSettings":{"maxThroughput":4000}},"resource":{"id":"users","shardKey":{"_id":"Hash"}}}}`
+
+		if s.bodies[users] != exp || string(out) != `{"options":{"autoscaleSettings":{"maxThroughput":4000}},"resource":{"id":"users","shardKey":{"_id":"Hash"}}}` {
+			t.Errorf("should be identical, got %s", s.bodies[users])
+		}
+
+		if len(s.requests) != 3 || s.requests[1] != "GET /operations/1" {
+			t.Errorf("should poll the operation, got %v", s.requests)
+		}
+	}()
+
+	// OK on exists and show.
+	func() {
+		for name, exp := range map[string]string{"users": "true", "orders": "false"} {
+			cmd := AzureCommand{Collection: name}
+			args, _ := cmd.CreateCommand(Exists, "develop", "account", "demo")
+
+			out, err := client.Execute(ctx, args)
+
+			if err != nil || string(out) != exp {
+				t.Errorf("should be %s, got %s, error %s", exp, out, err)
+			}
+		}
+
+		cmd := AzureCommand{Collection: "orders"}
+		args, _ := cmd.CreateCommand(Show, "develop", "account", "demo")
+
+		_, err := client.Execute(ctx, args)
+
+		if err == nil || !strings.Contains(err.Error(), "404 NotFound") {
+			t.Errorf("should fail with status, error %s", err)
+		}
+	}()
+
+	// OK on throughput update of database with subscription of arguments.
+	func() {
+		args := []string{"cosmosdb", "mongodb", "database", "throughput", "update", "-g", "develop", "-a", "account", "-n", "demo", "--throughput", "800", "--subscription", "other"}
+
+		if _, err := client.Execute(ctx, args); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		path := strings.Replace(testAccountPath, "/sub/", "/other/", 1) + "/mongodbDatabases/demo/throughputSettings/default"
+
+		if s.bodies[path] != `{"properties":{"resource":{"throughput":800}}}` {
+			t.Errorf("should update throughput, got %v", s.bodies)
+		}
+	}()
+
+	// OK on throughput migrate of collection, which waits for Location.
+	func() {
+		autoScale := true
+		cmd := AzureCommand{Collection: "users", AutoScale: &autoScale}
+		args, _ := cmd.CreateCommand(ThroughputMigrate, "develop", "account", "demo")
+
+		out, err := client.Execute(ctx, args)
+
+		if err != nil || string(out) != `{"resource":{"autoscaleSettings":{"maxThroughput":4000}}}` {
+			t.Errorf("should migrate to autoscale, got %s, error %s", out, err)
+		}
+
+		if _, err := client.Execute(ctx, []string{"cosmosdb", "mongodb", "collection", "throughput", "migrate", "-g", "develop", "-a", "account", "-d", "demo", "-n", "users", "--throughput-type", "serverless"}); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+
+	// OK on delete.
+	func() {
+		cmd := AzureCommand{Collection: "users"}
+		args, _ := cmd.CreateCommand(Delete, "develop", "account", "demo")
+
+		if _, err := client.Execute(ctx, append(args, "--yes")); err != nil {
+			t.Errorf("should not fail, error %s", err)
+		}
+
+		if _, ok := s.resources[users]; ok {
+			t.Errorf("should be deleted")
+		}
+	}()
+
+	// Fails on unsupported command and missing subscription.
+	func() {
+		if _, err := client.Execute(ctx, []string{"cosmosdb", "sql", "container", "create"}); err == nil {
+			t.Errorf("should fail")
+		}
+
+		noSub := &ARMClient{BaseURL: client.BaseURL}
+		cmd := AzureCommand{Collection: "users"}
+		args, _ := cmd.CreateCommand(Show, "develop", "account", "demo")
+
+		if _, err := noSub.Execute(ctx, args); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
+
+func TestARMThrottled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error": {"code": "TooManyRequests", "message": "Request rate is large."}}`)
+	}))
+	defer srv.Close()
+
+	client := &ARMClient{BaseURL: srv.URL, Subscription: "sub"}
+	cmd := AzureCommand{Collection: "users"}
+	args, _ := cmd.CreateCommand(Show, "develop", "account", "demo")
+
+	_, err := client.Execute(context.Background(), args)
+
+	hint, ok := throttled(err)
+
+	if !ok || hint != 3*time.Second {
+		t.Errorf("should be throttled with Retry-After, got %s, error %s", hint, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	type pattern struct {
+		header string
+		min    time.Duration
+		max    time.Duration
+	}
+
+	pats := []pattern{
+		{"3", 3 * time.Second, 3 * time.Second},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"", time.Second, time.Second},
+		{"soon", time.Second, time.Second},
+	}
+
+	for idx, p := range pats {
+		res := &http.Response{Header: http.Header{}}
+		res.Header.Set("Retry-After", p.header)
+
+		if got := retryAfter(res, time.Second); got < p.min || got > p.max {
+			t.Errorf("case %d expected between %s and %s, got %s", idx, p.min, p.max, got)
+		}
+	}
+}
+
+func TestAzAdminWithARM(t *testing.T) {
+	s, client := newARMStandIn(t)
+
+	m := newTestMigrator("demo", Options{ResourceGroup: "develop", AccountName: "account", Executor: client})
+
+	raw := `{"collection": "users", "shardKey": "_id", "throughput": 400}`
+
+	if _, err := m.adminRunner().run(context.Background(), raw, Create); err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	if _, ok := s.resources[testAccountPath+"/mongodbDatabases/demo/collections/users"]; !ok {
+		t.Errorf("should create collection without az, got %v", s.requests)
+	}
+
+	if _, err := m.adminRunner().run(context.Background(), `{"scope": "database", "autoScale": true, "throughput": 4000}`, Create); err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	if got := s.bodies[testAccountPath+"/mongodbDatabases/demo"]; got != `{"properties":{"options":{"autoscaleSettings":{"maxThroughput":4000}},"resource":{"id":"demo"}}}` {
+		t.Errorf("should create database with shared throughput, got %s", got)
+	}
+}
+
+// roundTripper is http.RoundTripper by a function.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestEnvironmentToken(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		r.ParseForm()
+
+		if r.URL.Path != "/tenant/oauth2/v2.0/token" || r.Form.Get("scope") != "https://arm.example/.default" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error": "invalid_request", "error_description": "unexpected request"}`)
+			return
+		}
+
+		io.WriteString(w, `{"access_token": "issued", "expires_in": 3600}`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	// OK with access token as is.
+	func() {
+		t.Setenv("AZURE_ACCESS_TOKEN", "given")
+
+		got, err := EnvironmentToken(srv.URL, "https://arm.example", nil)(ctx)
+
+		if err != nil || got != "given" {
+			t.Errorf("should use given token, got %s, error %s", got, err)
+		}
+	}()
+
+	// OK with service principal, cached until expired.
+	func() {
+		t.Setenv("AZURE_ACCESS_TOKEN", "")
+		t.Setenv("AZURE_TENANT_ID", "tenant")
+		t.Setenv("AZURE_CLIENT_ID", "client")
+		t.Setenv("AZURE_CLIENT_SECRET", "secret")
+
+		// Requests go through the given client, e.g. with proxy of ARMClient.
+		sent := 0
+		client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			sent++
+			return http.DefaultTransport.RoundTrip(r)
+		})}
+
+		token := EnvironmentToken(srv.URL, "https://arm.example/", client)
+
+		for i := 0; i < 2; i++ {
+			got, err := token(ctx)
+
+			if err != nil || got != "issued" {
+				t.Errorf("should issue token, got %s, error %s", got, err)
+			}
+		}
+
+		if calls != 1 || sent != 1 {
+			t.Errorf("should cache token with the given client, got %d calls, %d sent", calls, sent)
+		}
+	}()
+
+	// Fails without credentials.
+	func() {
+		t.Setenv("AZURE_CLIENT_SECRET", "")
+
+		if _, err := EnvironmentToken(srv.URL, "", nil)(ctx); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAuthority is the endpoint of Microsoft Entra ID on the public cloud.
+const DefaultAuthority = "https://login.microsoftonline.com"
+
+/*
+EnvironmentToken returns token source of ARMClient configured by environment variables.
+AZURE_ACCESS_TOKEN is used as is when given, e.g. from `az account get-access-token` on another machine.
+Otherwise AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET of a service principal are
+exchanged at authority for a token of resource, which is the endpoint of Azure Resource Manager.
+Token requests are sent by client, which should be HTTPClient of ARMClient; http.DefaultClient when nil.
+
+環境変数から ARMClient のトークンを取得する関数を返します。
+*/
+func EnvironmentToken(authority string, resource string, client *http.Client) func(ctx context.Context) (string, error) {
+	if authority == "" {
+		authority = DefaultAuthority
+	}
+
+	if resource == "" {
+		resource = DefaultARMEndpoint
+	}
+
+	var (
+		mu      sync.Mutex
+		token   string
+		expires time.Time
+	)
+
+	return func(ctx context.Context) (string, error) {
+		if v := os.Getenv("AZURE_ACCESS_TOKEN"); v != "" {
+			return v, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Refresh a little early, so the token does not expire during a request.
+		if token != "" && time.Now().Add(time.Minute).Before(expires) {
+			return token, nil
+		}
+
+		tenant, id, secret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+
+		if tenant == "" || id == "" || secret == "" {
+			return "", fmt.Errorf("either AZURE_ACCESS_TOKEN or AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET are required")
+		}
+
+		v, ttl, err := clientSecretToken(ctx, client, authority, tenant, id, secret, resource)
+
+		if err != nil {
+			return "", err
+		}
+
+		token, expires = v, time.Now().Add(ttl)
+
+		return token, nil
+	}
+}
+
+// clientSecretToken runs client credentials flow of OAuth 2.0.
+func clientSecretToken(ctx context.Context, client *http.Client, authority, tenant, id, secret, resource string) (string, time.Duration, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {id},
+		"client_secret": {secret},
+		"scope":         {strings.TrimSuffix(resource, "/") + "/.default"},
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authority, "/"), url.PathEscape(tenant))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer res.Body.Close()
+
+	var out struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", 0, fmt.Errorf("incorrect token response with %d, %s", res.StatusCode, err)
+	}
+
+	if res.StatusCode != http.StatusOK || out.AccessToken == "" {
+		return "", 0, fmt.Errorf("failed to get token with %d, %s: %s", res.StatusCode, out.Error, out.ErrorDescription)
+	}
+
+	return out.AccessToken, time.Duration(out.ExpiresIn) * time.Second, nil
+}
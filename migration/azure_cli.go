@@ -0,0 +1,227 @@
+package migration
+
+// 任意のazコマンドを実行する
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AzureCommand はAzure Cosmos DB for MongoDBのコレクションを管理するためのコマンドを作成するための構造体
+type AzureCommand struct {
+	Description string `json:"description"`
+	Collection  string `json:"collection"`
+	ShardKey    string `json:"shardKey"`
+	SharedRU    bool   `json:"sharedRU"`
+	AutoScale   *bool  `json:"autoScale"`
+	Throughput  *int   `json:"throughput"`
+	// Action は実行する操作。省略時は up で create、down で delete となる
+	Action Action `json:"action"`
+	// Scope は操作の対象。省略時は collection
+	Scope Scope `json:"scope"`
+}
+type Action string
+
+const (
+	Create Action = "create"
+	Delete Action = "delete"
+	List   Action = "list"
+	Show   Action = "show"
+	Exists Action = "exists"
+	// ThroughputShow reads throughput of a collection with dedicated RU.
+	ThroughputShow Action = "throughput show"
+	// ThroughputUpdate changes RU/s, maximum RU/s when autoScale is true.
+	ThroughputUpdate Action = "throughput update"
+	// ThroughputMigrate switches throughput to autoscale when autoScale is true, to manual otherwise.
+	ThroughputMigrate Action = "throughput migrate"
+)
+
+// Scope は AzureCommand の操作対象
+type Scope string
+
+const (
+	ScopeCollection Scope = "collection"
+	ScopeDatabase   Scope = "database"
+)
+
+// resolve returns Action given in the file, or the given one when omitted.
+// Files can only change throughput, other actions are given by up and down.
+func (ac *AzureCommand) resolve(given Action) (Action, error) {
+	switch ac.Action {
+	case "":
+		return given, nil
+	case ThroughputUpdate, ThroughputMigrate:
+		return ac.Action, nil
+	default:
+		return "", fmt.Errorf("action %q is not supported in files, use either %q or %q", ac.Action, ThroughputUpdate, ThroughputMigrate)
+	}
+}
+
+/*
+Azure Cosmos DB for MongoDBのコレクションを管理するためのコマンドを作成する
+
+	※コレクション作成時の注意点
+	データベース共有RUからコンテナー固有RUには変更できません。
+	コンテナー固有RU/s の手動スループットを設定する場合は、--throughput パラメーターを使用します。
+	az cosmosdb mongodb collection create -g MyResourceGroup -a MyAccount -d MyDatabase -n MyCollection --shard "ShardingKey" --idx @indexes-file.json --throughput "400"
+	コンテナー固有RU/s の自動スループットを設定する場合は、--max-throughput パラメーターを使用します。
+	az cosmosdb mongodb collection create -g MyResourceGroup -a MyAccount -d MyDatabase -n MyCollection --shard "ShardingKey" --idx @indexes-file.json --max-throughput "4000"
+	データベース共有RU/s のスループットを設定する場合は、--throughput　--max-throughput パラメーターを指定しません。
+	az cosmosdb mongodb collection create -g MyResourceGroup -a MyAccount -d MyDatabase -n MyCollection --shard "ShardingKey" --idx @indexes-file.json
+*/
+func (ac *AzureCommand) CreateCommand(action Action, rg string, accountName string, dbName string) ([]string, error) {
+	switch ac.Scope {
+	case "", ScopeCollection:
+	case ScopeDatabase:
+		return ac.databaseCommand(action, rg, accountName, dbName)
+	default:
+		return nil, fmt.Errorf("invalid scope")
+	}
+
+	base := []string{"cosmosdb", "mongodb", "collection"}
+
+	if ac.Collection == "" {
+		return nil, fmt.Errorf("collection name is required")
+	}
+	if rg == "" {
+		return nil, fmt.Errorf("resource group name is required")
+	}
+	if accountName == "" {
+		return nil, fmt.Errorf("account name is required")
+	}
+	// コマンドの作成
+	var args []string
+	switch action {
+	case Create:
+		args = append(base, "create", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection)
+		if ac.ShardKey != "" {
+			args = append(args, "--shard", ac.ShardKey)
+		} else {
+			return nil, fmt.Errorf("shard key is required")
+		}
+		// データベース共有RU/sがオフかつthroughputが指定されていない場合はエラー
+		if !ac.SharedRU && ac.Throughput == nil {
+			return nil, fmt.Errorf("throughput and maxThroughput cannot be specified at the same time")
+		}
+		if ac.SharedRU {
+			break
+		} else {
+			tp, err := ac.throughputArgs()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, tp...)
+		}
+	case Delete:
+		args = append(base, "delete", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection)
+	case List:
+		args = append(base, "list", "-g", rg, "-a", accountName, "-d", dbName)
+	case Show:
+		args = append(base, "show", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection)
+	case Exists:
+		args = append(base, "exists", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection)
+	case ThroughputShow:
+		args = append(base, "throughput", "show", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection)
+	case ThroughputUpdate, ThroughputMigrate:
+		args = append(append(base, strings.Fields(string(action))...), "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection)
+		tp, err := ac.throughputChange(action)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tp...)
+	default:
+		return nil, fmt.Errorf("invalid action")
+
+	}
+	return args, nil
+}
+
+// databaseCommand はデータベースを対象とするコマンドを作成する。データベース名は接続先のデータベースとなる
+func (ac *AzureCommand) databaseCommand(action Action, rg string, accountName string, dbName string) ([]string, error) {
+	base := []string{"cosmosdb", "mongodb", "database"}
+
+	if rg == "" {
+		return nil, fmt.Errorf("resource group name is required")
+	}
+	if accountName == "" {
+		return nil, fmt.Errorf("account name is required")
+	}
+
+	var args []string
+	switch action {
+	case Create:
+		args = append(base, "create", "-g", rg, "-a", accountName, "-n", dbName)
+		// throughput を省略した場合は共有RUを持たないデータベースとなる
+		if ac.Throughput == nil {
+			if ac.AutoScale != nil && *ac.AutoScale {
+				return nil, fmt.Errorf("throughput is required for autoScale")
+			}
+			break
+		}
+		tp, err := ac.throughputArgs()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tp...)
+	case Delete:
+		// マイグレーション履歴も削除されるため対象外とする
+		return nil, fmt.Errorf("database cannot be deleted by migration, which deletes migration history")
+	case Show, Exists:
+		args = append(base, string(action), "-g", rg, "-a", accountName, "-n", dbName)
+	case ThroughputShow:
+		args = append(base, "throughput", "show", "-g", rg, "-a", accountName, "-n", dbName)
+	case ThroughputUpdate, ThroughputMigrate:
+		args = append(append(base, strings.Fields(string(action))...), "-g", rg, "-a", accountName, "-n", dbName)
+		tp, err := ac.throughputChange(action)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tp...)
+	default:
+		return nil, fmt.Errorf("invalid action")
+	}
+	return args, nil
+}
+
+// throughputArgs は固有RU/sの引数を作成する。自動スケールは4000、手動は400以上とする
+func (ac *AzureCommand) throughputArgs() ([]string, error) {
+	if ac.AutoScale != nil && *ac.AutoScale {
+		if *ac.Throughput < 4000 {
+			return nil, fmt.Errorf("maxThroughput must be greater than or equal to 4000")
+		}
+		return []string{"--max-throughput", fmt.Sprint(*ac.Throughput)}, nil
+	}
+	if *ac.Throughput < 400 {
+		return nil, fmt.Errorf("throughput must be greater than or equal to 400")
+	}
+	return []string{"--throughput", fmt.Sprint(*ac.Throughput)}, nil
+}
+
+// throughputChange は throughput update / migrate の引数を作成する
+func (ac *AzureCommand) throughputChange(action Action) ([]string, error) {
+	if action == ThroughputUpdate {
+		if ac.Throughput == nil {
+			return nil, fmt.Errorf("throughput is required to update throughput")
+		}
+		return ac.throughputArgs()
+	}
+
+	// 手動と自動スケールの切り替え後の値は throughput update で指定する
+	if ac.AutoScale == nil {
+		return nil, fmt.Errorf("autoScale is required to migrate throughput")
+	}
+	if ac.Throughput != nil {
+		return nil, fmt.Errorf("throughput cannot be specified to migrate throughput, use throughput update after migration")
+	}
+	if *ac.AutoScale {
+		return []string{"--throughput-type", "autoscale"}, nil
+	}
+	return []string{"--throughput-type", "manual"}, nil
+}
+
+// AzExcute は任意のazコマンドを実行する。ctx がキャンセルされた場合はプロセスを終了する
+// エラーには az の標準エラー出力が含まれる
+func AzExcute(ctx context.Context, args []string) ([]byte, error) {
+	return AzCLI{}.Execute(ctx, args)
+}
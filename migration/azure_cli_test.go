@@ -0,0 +1,405 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollection(t *testing.T) {
+	ac := AzureCommand{
+		Collection: "MyCollection",
+		ShardKey:   "_id",
+		SharedRU:   false,
+		AutoScale:  nil,
+		Throughput: nil,
+	}
+
+	rg := "MyResourceGroup"
+	accountName := "MyAccount"
+	dbName := "MyDatabase"
+
+	t.Run("success - 固有RU autoscale無効", func(t *testing.T) {
+		action := Create
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "create", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection, "--shard", "_id", "--throughput", "400"}
+		ac.ShardKey = "_id"
+		ac.AutoScale = new(bool)
+		*ac.AutoScale = false
+		throughput := 400
+		ac.Throughput = &throughput
+
+		args, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - 固有RU autoscale有効", func(t *testing.T) {
+		action := Create
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "create", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection, "--shard", "_id", "--max-throughput", "4000"}
+		ac.ShardKey = "_id"
+		ac.AutoScale = new(bool)
+		*ac.AutoScale = true
+		throughput := 4000
+		ac.Throughput = &throughput
+
+		args, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - 共有RU", func(t *testing.T) {
+		action := Create
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "create", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection, "--shard", "_id"}
+		ac.ShardKey = "_id"
+		ac.SharedRU = true
+
+		args, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("error - collection name is required", func(t *testing.T) {
+		action := Create
+		ac.Collection = ""
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "collection name is required") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - resource group name is required", func(t *testing.T) {
+		action := Create
+		rg := ""
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "resource group name is required") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - account name is required", func(t *testing.T) {
+		action := Create
+		accountName := ""
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "account name is required") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - throughput and maxThroughput cannot be specified at the same time", func(t *testing.T) {
+		action := Create
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "throughput and maxThroughput cannot be specified at the same time") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - maxThroughput must be greater than or equal to 4000", func(t *testing.T) {
+		action := Create
+		ac.Throughput = new(int)
+		*ac.Throughput = 100
+		ac.AutoScale = new(bool)
+		*ac.AutoScale = true
+		ac.SharedRU = false
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "maxThroughput must be greater than or equal to 4000") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - throughput must be greater than or equal to 400", func(t *testing.T) {
+		action := Create
+		ac.Throughput = new(int)
+		*ac.Throughput = 100
+		ac.AutoScale = new(bool)
+		*ac.AutoScale = false
+		ac.SharedRU = false
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "throughput must be greater than or equal to 400") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		action := Delete
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "delete", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection}
+
+		args, _ := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		action := List
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "list", "-g", rg, "-a", accountName, "-d", dbName}
+
+		args, _ := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("Show", func(t *testing.T) {
+		action := Show
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "show", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection}
+
+		args, _ := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		action := Exists
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "exists", "-g", rg, "-a", accountName, "-d", dbName, "-n", ac.Collection}
+
+		args, _ := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("error - invalid action", func(t *testing.T) {
+		action := Action("invalid")
+
+		_, err := ac.CreateCommand(action, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(err.Error(), "invalid action") {
+			t.Errorf("expected err %v", err)
+		}
+	})
+}
+
+func TestThroughputCommand(t *testing.T) {
+	rg := "MyResourceGroup"
+	accountName := "MyAccount"
+	dbName := "MyDatabase"
+
+	on, off := true, false
+	manual, autoscale, low := 800, 5000, 300
+
+	t.Run("success - コレクションのRU変更", func(t *testing.T) {
+		ac := AzureCommand{Collection: "MyCollection", Throughput: &manual}
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "throughput", "update", "-g", rg, "-a", accountName, "-d", dbName, "-n", "MyCollection", "--throughput", "800"}
+
+		args, err := ac.CreateCommand(ThroughputUpdate, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - データベースの最大RU変更", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &on, Throughput: &autoscale}
+		expectedArgs := []string{"cosmosdb", "mongodb", "database", "throughput", "update", "-g", rg, "-a", accountName, "-n", dbName, "--max-throughput", "5000"}
+
+		args, err := ac.CreateCommand(ThroughputUpdate, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - 自動スケールへの切り替え", func(t *testing.T) {
+		ac := AzureCommand{Collection: "MyCollection", AutoScale: &on}
+		expectedArgs := []string{"cosmosdb", "mongodb", "collection", "throughput", "migrate", "-g", rg, "-a", accountName, "-d", dbName, "-n", "MyCollection", "--throughput-type", "autoscale"}
+
+		args, err := ac.CreateCommand(ThroughputMigrate, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - 手動への切り替え", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &off}
+		expectedArgs := []string{"cosmosdb", "mongodb", "database", "throughput", "migrate", "-g", rg, "-a", accountName, "-n", dbName, "--throughput-type", "manual"}
+
+		args, err := ac.CreateCommand(ThroughputMigrate, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("error - throughput must be greater than or equal to 400", func(t *testing.T) {
+		ac := AzureCommand{Collection: "MyCollection", Throughput: &low}
+
+		_, err := ac.CreateCommand(ThroughputUpdate, rg, accountName, dbName)
+
+		if err == nil || err.Error() != "throughput must be greater than or equal to 400" {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - maxThroughput must be greater than or equal to 4000", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &on, Throughput: &manual}
+
+		_, err := ac.CreateCommand(ThroughputUpdate, rg, accountName, dbName)
+
+		if err == nil || err.Error() != "maxThroughput must be greater than or equal to 4000" {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - throughput is required", func(t *testing.T) {
+		ac := AzureCommand{Collection: "MyCollection"}
+
+		if _, err := ac.CreateCommand(ThroughputUpdate, rg, accountName, dbName); err == nil {
+			t.Errorf("should fail")
+		}
+	})
+
+	t.Run("error - migrate requires autoScale only", func(t *testing.T) {
+		for _, ac := range []AzureCommand{
+			{Collection: "MyCollection"},
+			{Collection: "MyCollection", AutoScale: &on, Throughput: &autoscale},
+		} {
+			if _, err := ac.CreateCommand(ThroughputMigrate, rg, accountName, dbName); err == nil {
+				t.Errorf("should fail, got %v", ac)
+			}
+		}
+	})
+
+	t.Run("error - invalid scope", func(t *testing.T) {
+		ac := AzureCommand{Scope: "account", Throughput: &manual}
+
+		_, err := ac.CreateCommand(ThroughputUpdate, rg, accountName, dbName)
+
+		if err == nil || err.Error() != "invalid scope" {
+			t.Errorf("expected err %v", err)
+		}
+	})
+}
+
+func TestDatabaseCommand(t *testing.T) {
+	rg := "MyResourceGroup"
+	accountName := "MyAccount"
+	dbName := "MyDatabase"
+
+	on, off := true, false
+	manual, autoscale := 400, 4000
+
+	t.Run("success - 共有RUなし", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase}
+		expectedArgs := []string{"cosmosdb", "mongodb", "database", "create", "-g", rg, "-a", accountName, "-n", dbName}
+
+		args, err := ac.CreateCommand(Create, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - 共有RU autoscale無効", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &off, Throughput: &manual}
+		expectedArgs := []string{"cosmosdb", "mongodb", "database", "create", "-g", rg, "-a", accountName, "-n", dbName, "--throughput", "400"}
+
+		args, err := ac.CreateCommand(Create, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("success - 共有RU autoscale有効", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &on, Throughput: &autoscale}
+		expectedArgs := []string{"cosmosdb", "mongodb", "database", "create", "-g", rg, "-a", accountName, "-n", dbName, "--max-throughput", "4000"}
+
+		args, err := ac.CreateCommand(Create, rg, accountName, dbName)
+
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("expected args %v, got %v, err %v", expectedArgs, args, err)
+		}
+	})
+
+	t.Run("error - throughput is required for autoScale", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &on}
+
+		_, err := ac.CreateCommand(Create, rg, accountName, dbName)
+
+		if err == nil || err.Error() != "throughput is required for autoScale" {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - maxThroughput must be greater than or equal to 4000", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase, AutoScale: &on, Throughput: &manual}
+
+		_, err := ac.CreateCommand(Create, rg, accountName, dbName)
+
+		if err == nil || err.Error() != "maxThroughput must be greater than or equal to 4000" {
+			t.Errorf("expected err %v", err)
+		}
+	})
+
+	t.Run("error - database cannot be deleted", func(t *testing.T) {
+		ac := AzureCommand{Scope: ScopeDatabase}
+
+		if _, err := ac.CreateCommand(Delete, rg, accountName, dbName); err == nil {
+			t.Errorf("should fail")
+		}
+	})
+}
+
+func TestResolveAction(t *testing.T) {
+	type pattern struct {
+		action Action
+		given  Action
+		exp    Action
+		fails  bool
+	}
+
+	pats := []pattern{
+		{"", Create, Create, false},
+		{"", Delete, Delete, false},
+		{ThroughputUpdate, Create, ThroughputUpdate, false},
+		{ThroughputMigrate, Delete, ThroughputMigrate, false},
+		{Create, Delete, "", true},
+		{Delete, Create, "", true},
+		{Show, Create, "", true},
+		{Exists, Create, "", true},
+		{List, Create, "", true},
+		{ThroughputShow, Create, "", true},
+		{"drop", Create, "", true},
+	}
+
+	for idx, p := range pats {
+		ac := AzureCommand{Collection: "MyCollection", Action: p.action}
+
+		got, err := ac.resolve(p.given)
+
+		if p.fails {
+			if err == nil {
+				t.Errorf("case %d should fail, got %s", idx, got)
+			}
+
+			continue
+		}
+
+		if err != nil || got != p.exp {
+			t.Errorf("case %d expected %s, got %s, error %s", idx, p.exp, got, err)
+		}
+	}
+}
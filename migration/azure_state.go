@@ -0,0 +1,180 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// sharedThroughput describes a collection without dedicated throughput.
+	sharedThroughput = "shared"
+	// noThroughput describes a database without shared throughput.
+	noThroughput = "none"
+)
+
+// collectionShow is output of `az cosmosdb mongodb collection show` needed for comparison.
+type collectionShow struct {
+	Resource struct {
+		ShardKey map[string]string `json:"shardKey"`
+	} `json:"resource"`
+}
+
+// throughputShow is output of `az cosmosdb mongodb collection throughput show`.
+type throughputShow struct {
+	Resource struct {
+		Throughput        *int `json:"throughput"`
+		AutoscaleSettings *struct {
+			MaxThroughput int `json:"maxThroughput"`
+		} `json:"autoscaleSettings"`
+	} `json:"resource"`
+}
+
+// describeThroughput returns throughput as "shared", "none", "manual 400" or "autoscale 4000".
+func (ac *AzureCommand) describeThroughput() string {
+	if ac.Scope == ScopeDatabase {
+		if ac.Throughput == nil {
+			return noThroughput
+		}
+	} else if ac.SharedRU {
+		return sharedThroughput
+	}
+
+	mode := "manual"
+
+	if ac.AutoScale != nil && *ac.AutoScale {
+		mode = "autoscale"
+	}
+
+	if ac.Throughput == nil {
+		return mode
+	}
+
+	return fmt.Sprintf("%s %d", mode, *ac.Throughput)
+}
+
+func (t throughputShow) describe() string {
+	if a := t.Resource.AutoscaleSettings; a != nil && a.MaxThroughput > 0 {
+		return fmt.Sprintf("autoscale %d", a.MaxThroughput)
+	}
+
+	if t.Resource.Throughput != nil {
+		return fmt.Sprintf("manual %d", *t.Resource.Throughput)
+	}
+
+	return sharedThroughput
+}
+
+func (c collectionShow) shardKey() string {
+	keys := make([]string, 0, len(c.Resource.ShardKey))
+
+	for k := range c.Resource.ShardKey {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
+
+// satisfied reports whether the collection or database already exists as the command describes,
+// and fails with the difference when it exists with other settings.
+func (a azAdmin) satisfied(ctx context.Context, cmd AzureCommand) (bool, error) {
+	out, err := a.execute(ctx, cmd, Exists)
+
+	if err != nil {
+		return false, err
+	}
+
+	if strings.TrimSpace(string(out)) != "true" {
+		return false, nil
+	}
+
+	var diffs []string
+
+	// Databases have no shard key.
+	if cmd.Scope != ScopeDatabase {
+		out, err = a.execute(ctx, cmd, Show)
+
+		if err != nil {
+			return false, err
+		}
+
+		var shown collectionShow
+
+		if err := json.Unmarshal(out, &shown); err != nil {
+			return false, fmt.Errorf("incorrect output of collection show, %s", err)
+		}
+
+		if got := shown.shardKey(); got != cmd.ShardKey {
+			diffs = append(diffs, fmt.Sprintf("shardKey: expected %s, actual %s", cmd.ShardKey, got))
+		}
+	}
+
+	// Throughput settings do not exist for collections sharing throughput of the database,
+	// nor for databases without shared throughput.
+	actual := sharedThroughput
+
+	if cmd.Scope == ScopeDatabase {
+		actual = noThroughput
+	}
+
+	out, err = a.execute(ctx, cmd, ThroughputShow)
+
+	switch {
+	case err == nil:
+		var tp throughputShow
+
+		if err := json.Unmarshal(out, &tp); err != nil {
+			return false, fmt.Errorf("incorrect output of throughput show, %s", err)
+		}
+
+		actual = tp.describe()
+	case !notFound(err):
+		return false, fmt.Errorf("failed to read throughput of %s, %s", a.target(cmd), err)
+	}
+
+	if exp := cmd.describeThroughput(); actual != exp {
+		diffs = append(diffs, fmt.Sprintf("throughput: expected %s, actual %s", exp, actual))
+	}
+
+	if len(diffs) > 0 {
+		return false, fmt.Errorf("%s already exists with different settings, %s", a.target(cmd), strings.Join(diffs, "; "))
+	}
+
+	return true, nil
+}
+
+// target returns the resource of the command such as "collection users" or "database demo".
+func (a azAdmin) target(cmd AzureCommand) string {
+	if cmd.Scope == ScopeDatabase {
+		return "database " + a.m.db.Name()
+	}
+
+	return "collection " + cmd.Collection
+}
+
+func (a azAdmin) execute(ctx context.Context, cmd AzureCommand, action Action) ([]byte, error) {
+	args, err := a.m.azArgs(cmd, action)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.m.opts.Executor.Execute(ctx, args)
+}
+
+// notFound reports whether err tells the resource does not exist, either from az or Azure Resource Manager.
+func notFound(err error) bool {
+	var ae *ARMError
+
+	if errors.As(err, &ae) {
+		return ae.StatusCode == http.StatusNotFound
+	}
+
+	return strings.Contains(err.Error(), "NotFound") || strings.Contains(strings.ToLower(err.Error()), "not found")
+}
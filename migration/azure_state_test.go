@@ -0,0 +1,183 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// azureStandIn replies to az of a collection as it exists with the given outputs, nothing when show is empty.
+func azureStandIn(show, throughput string) *Recorder {
+	return &Recorder{
+		Reply: func(args []string) ([]byte, error) {
+			switch strings.Join(azCommand(args), " ") {
+			case "cosmosdb mongodb collection exists":
+				return []byte(map[bool]string{true: "true", false: "false"}[show != ""]), nil
+			case "cosmosdb mongodb collection show":
+				return []byte(show), nil
+			case "cosmosdb mongodb collection throughput show":
+				if throughput == "" {
+					return nil, errors.New("(NotFound) Throughput settings not found")
+				}
+
+				return []byte(throughput), nil
+			default:
+				return nil, nil
+			}
+		},
+	}
+}
+
+func TestAzAdminCreateIdempotent(t *testing.T) {
+	ctx := context.Background()
+
+	dedicated := `{"collection": "users", "shardKey": "_id", "autoScale": true, "throughput": 4000}`
+	shared := `{"collection": "users", "shardKey": "_id", "sharedRU": true}`
+
+	users := `{"name": "users", "resource": {"id": "users", "shardKey": {"_id": "Hash"}}}`
+	autoscale := `{"resource": {"throughput": 400, "autoscaleSettings": {"maxThroughput": 4000}}}`
+
+	type pattern struct {
+		raw        string
+		show       string
+		throughput string
+		created    bool
+		fails      string
+	}
+
+	pats := []pattern{
+		{dedicated, "", "", true, ""},
+		{dedicated, users, autoscale, false, ""},
+		{shared, users, "", false, ""},
+		{dedicated, users, `{"resource": {"throughput": 400}}`, false, "throughput: expected autoscale 4000, actual manual 400"},
+		{dedicated, `{"resource": {"shardKey": {"userId": "Hash"}}}`, autoscale, false, "shardKey: expected _id, actual userId"},
+		{shared, users, autoscale, false, "throughput: expected shared, actual autoscale 4000"},
+		{dedicated, users, "", false, "throughput: expected autoscale 4000, actual shared"},
+	}
+
+	for idx, p := range pats {
+		r := azureStandIn(p.show, p.throughput)
+		m := newTestMigrator("demo", Options{ResourceGroup: "develop", AccountName: "account", Executor: r})
+
+		reply, err := m.adminRunner().run(ctx, p.raw, Create)
+
+		if p.fails != "" {
+			if err == nil || !strings.Contains(err.Error(), p.fails) {
+				t.Errorf("case %d should fail with %s, error %s", idx, p.fails, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case %d should not fail, error %s", idx, err)
+			continue
+		}
+
+		calls := r.Calls()
+		created := len(calls) > 0 && calls[len(calls)-1][3] == "create"
+
+		if created != p.created {
+			t.Errorf("case %d expected create %v, got %v", idx, p.created, calls)
+		}
+
+		if !p.created && summarize(reply) != "note: collection users already exists with the same settings" {
+			t.Errorf("case %d should tell it exists, got %v", idx, reply)
+		}
+	}
+
+	// Fails when throughput cannot be read.
+	func() {
+		r := &Recorder{
+			Reply: func(args []string) ([]byte, error) {
+				switch args[3] {
+				case "exists":
+					return []byte("true"), nil
+				case "show":
+					return []byte(users), nil
+				default:
+					return nil, errors.New("(AuthorizationFailed) not allowed")
+				}
+			},
+		}
+
+		m := newTestMigrator("demo", Options{ResourceGroup: "develop", AccountName: "account", Executor: r})
+
+		if _, err := m.adminRunner().run(ctx, dedicated, Create); err == nil || !strings.Contains(err.Error(), "AuthorizationFailed") {
+			t.Errorf("should fail, error %s", err)
+		}
+	}()
+}
+
+func TestAzAdminCreateDatabaseIdempotent(t *testing.T) {
+	ctx := context.Background()
+
+	shared := `{"scope": "database", "autoScale": true, "throughput": 4000}`
+	none := `{"scope": "database"}`
+
+	autoscale := `{"resource": {"throughput": 400, "autoscaleSettings": {"maxThroughput": 4000}}}`
+
+	type pattern struct {
+		raw        string
+		exists     bool
+		throughput string
+		created    bool
+		fails      string
+	}
+
+	pats := []pattern{
+		{shared, false, "", true, ""},
+		{shared, true, autoscale, false, ""},
+		{none, true, "", false, ""},
+		{shared, true, "", false, "throughput: expected autoscale 4000, actual none"},
+		{none, true, `{"resource": {"throughput": 400}}`, false, "throughput: expected none, actual manual 400"},
+	}
+
+	for idx, p := range pats {
+		r := &Recorder{
+			Reply: func(args []string) ([]byte, error) {
+				switch strings.Join(azCommand(args), " ") {
+				case "cosmosdb mongodb database exists":
+					return []byte(map[bool]string{true: "true", false: "false"}[p.exists]), nil
+				case "cosmosdb mongodb database throughput show":
+					if p.throughput == "" {
+						return nil, errors.New("(NotFound) Throughput settings not found")
+					}
+
+					return []byte(p.throughput), nil
+				default:
+					return nil, nil
+				}
+			},
+		}
+
+		m := newTestMigrator("demo", Options{ResourceGroup: "develop", AccountName: "account", Executor: r})
+
+		reply, err := m.adminRunner().run(ctx, p.raw, Create)
+
+		if p.fails != "" {
+			if err == nil || !strings.Contains(err.Error(), p.fails) {
+				t.Errorf("case %d should fail with %s, error %s", idx, p.fails, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case %d should not fail, error %s", idx, err)
+			continue
+		}
+
+		calls := r.Calls()
+		created := len(calls) > 0 && strings.Join(azCommand(calls[len(calls)-1]), " ") == "cosmosdb mongodb database create"
+
+		if created != p.created {
+			t.Errorf("case %d expected create %v, got %v", idx, p.created, calls)
+		}
+
+		if !p.created && summarize(reply) != "note: database demo already exists with the same settings" {
+			t.Errorf("case %d should tell it exists, got %v", idx, reply)
+		}
+	}
+}
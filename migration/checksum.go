@@ -0,0 +1,150 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"sort"
+)
+
+// Drift represents an applied file whose content has changed since.
+type Drift struct {
+	Version  string `json:"version"`
+	Recorded string `json:"recorded"`
+	Actual   string `json:"actual"`
+}
+
+// checksum returns hash of raw file content.
+// Line endings are normalized, so a checkout on Windows is not reported as modified.
+func checksum(raw []byte) string {
+	normalized := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	sum := sha256.Sum256(normalized)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// dirChecksums returns checksum of every migration file within the directory.
+func (m *Migrator) dirChecksums() (map[string]string, error) {
+	paths, err := m.listFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]string{}
+
+	for _, p := range paths {
+		got, err := fs.ReadFile(m.fsys, p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		out[filename(p)] = checksum(got)
+	}
+
+	return out, nil
+}
+
+// recordedChecksums returns the last accepted checksum of each version.
+func recordedChecksums(entries []Entry) map[string]string {
+	out := map[string]string{}
+
+	for _, e := range entries {
+		// A rolled back file may be edited freely before applied again.
+		if e.Status == StatusRolledBack {
+			delete(out, e.Version)
+			continue
+		}
+
+		if e.Checksum == "" {
+			continue
+		}
+
+		if e.Status == StatusApplied || e.Status == StatusRepaired {
+			out[e.Version] = e.Checksum
+		}
+	}
+
+	return out
+}
+
+func findDrifts(entries []Entry, actual map[string]string) []Drift {
+	var out []Drift
+
+	for v, rec := range recordedChecksums(entries) {
+		got, ok := actual[v]
+
+		// Files missing from disk are reported by status, not here.
+		if !ok || got == rec {
+			continue
+		}
+
+		out = append(out, Drift{Version: v, Recorded: rec, Actual: got})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Version < out[j].Version
+	})
+
+	return out
+}
+
+/*
+Verify returns applied files within the directory whose content has changed.
+
+適用済みファイルのうち、内容が変更されたものを返します。
+*/
+func (m *Migrator) Verify(ctx context.Context) ([]Drift, error) {
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := m.dirChecksums()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return findDrifts(entries, actual), nil
+}
+
+/*
+Repair accepts current content of every changed file by recording its new checksum while holding the lock.
+
+変更されたファイルの新しいチェックサムを履歴に記録し、変更を受け入れます。
+*/
+func (m *Migrator) Repair(ctx context.Context) ([]Drift, error) {
+	var drifts []Drift
+
+	err := m.locked(ctx, func() error {
+		var err error
+
+		drifts, err = m.Verify(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, d := range drifts {
+			e := newEntry(d.Version, StatusRepaired)
+			e.Checksum = d.Actual
+
+			if err := m.record(ctx, e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"os"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	lf := checksum([]byte("{\n  \"command\": {}\n}\n"))
+	crlf := checksum([]byte("{\r\n  \"command\": {}\r\n}\r\n"))
+
+	if lf != crlf {
+		t.Errorf("line endings should be normalized, got %s and %s", lf, crlf)
+	}
+
+	if lf == checksum([]byte("{}")) {
+		t.Errorf("different content should not match")
+	}
+}
+
+func TestParseCommandChecksum(t *testing.T) {
+	a, err := parseCommand(os.DirFS(".."), "examples/000000001_users.json", "demo")
+
+	if err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	b, err := parseCommand(os.DirFS(".."), "examples/000000001_users.json", "production")
+
+	if err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	if a.Checksum == "" || a.Checksum != b.Checksum {
+		t.Errorf("checksum should not depend on database name, got %s and %s", a.Checksum, b.Checksum)
+	}
+}
+
+func TestFindDrifts(t *testing.T) {
+	entries := []Entry{
+		{Version: "000000001_users.json", Status: StatusApplied, Checksum: "a"},
+		{Version: "000000002_admins.json", Status: StatusApplied, Checksum: "b"},
+		{Version: "000000002_admins.json", Status: StatusRepaired, Checksum: "c"},
+		{Version: "000000003-compound.json", Status: StatusBaseline},
+	}
+
+	actual := map[string]string{
+		"000000001_users.json":    "x",
+		"000000002_admins.json":   "c",
+		"000000003-compound.json": "y",
+	}
+
+	got := findDrifts(entries, actual)
+
+	if len(got) != 1 {
+		t.Errorf("should detect a single drift, got %#v", got)
+		return
+	}
+
+	if got[0].Version != "000000001_users.json" || got[0].Recorded != "a" || got[0].Actual != "x" {
+		t.Errorf("should be identical, got %#v", got[0])
+	}
+}
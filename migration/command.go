@@ -0,0 +1,264 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+// Command represents JSON for migration.
+type Command struct {
+	Version     string
+	Admin       string
+	General     string
+	DownAdmin   string
+	DownGeneral string
+	Checksum    string
+	// Timeout bounds each step of the file instead of Options, zero when not given.
+	Timeout time.Duration
+
+	// steps holds elements of "steps", nil when the file uses adminCommand and command.
+	steps []Step
+}
+
+// Kinds of Step.
+const (
+	StepAdmin   = "admin"
+	StepCommand = "command"
+)
+
+// Step represents a single unit of a file, executed in order by Apply.
+type Step struct {
+	Kind        string
+	Raw         string
+	Description string
+}
+
+// Steps returns parts of the file in executing order.
+// Position within the slice is recorded as progress, so a failed file can resume.
+func (c *Command) Steps() []Step {
+	if c.steps != nil {
+		return c.steps
+	}
+
+	var out []Step
+
+	if c.Admin != "" {
+		out = append(out, Step{Kind: StepAdmin, Raw: c.Admin})
+	}
+
+	if c.General != "" {
+		out = append(out, Step{Kind: StepCommand, Raw: c.General})
+	}
+
+	return out
+}
+
+// HasDown reports whether the file can be rolled back.
+func (c *Command) HasDown() bool {
+	return c.DownAdmin != "" || c.DownGeneral != ""
+}
+
+/*
+Parse command from file.
+
+The file should contain JSON with following structure:
+
+	{
+		"adminCommand": "JSON",
+		"command": "JSON",
+		"downAdminCommand": "JSON",
+		"downCommand": "JSON"
+	}
+
+downAdminCommand and downCommand are optional, used by `migrate down`.
+timeout is optional, given as duration such as "10m" for slow commands like createIndexes.
+
+Multiple commands can be given as steps instead of adminCommand and command,
+each element contains either adminCommand or command:
+
+	{
+		"steps": [
+			{"description": "text", "adminCommand": "JSON"},
+			{"description": "text", "command": "JSON"}
+		]
+	}
+*/
+func parseCommand(fsys fs.FS, name, dbname string) (*Command, error) {
+	if name == "" || dbname == "" {
+		return nil, fmt.Errorf("invalid input for parse")
+	}
+
+	got, err := fs.ReadFile(fsys, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Command{}
+
+	// Hash before the magic overwrite, so the same file matches on every database.
+	out.Checksum = checksum(got)
+
+	// Doing magic overwrite.
+	got = []byte(strings.ReplaceAll(string(got), "<db>", dbname))
+
+	// Populate version.
+	out.Version = filename(name)
+
+	// Extract string representations.
+	// You cannot use standard json.Unmarshal command, because
+	// the attributes are object and unmarshaller returns error.
+
+	fields := []struct {
+		key string
+		dst *string
+	}{
+		{"adminCommand", &out.Admin},
+		{"command", &out.General},
+		{"downAdminCommand", &out.DownAdmin},
+		{"downCommand", &out.DownGeneral},
+	}
+
+	for _, f := range fields {
+		val, err := extract(got, f.key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		*f.dst = val
+	}
+
+	timeout, err := parseTimeout(got)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out.Timeout = timeout
+
+	steps, err := parseSteps(got)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if steps != nil && (out.Admin != "" || out.General != "") {
+		return nil, fmt.Errorf("steps cannot be combined with adminCommand nor command")
+	}
+
+	out.steps = steps
+
+	return out, nil
+}
+
+// parseTimeout returns "timeout" as duration, or zero when it does not exist.
+func parseTimeout(got []byte) (time.Duration, error) {
+	val, typ, _, err := jsonparser.Get(got, "timeout")
+
+	if typ == jsonparser.NotExist {
+		return 0, nil
+	}
+
+	if err != nil || typ != jsonparser.String {
+		return 0, fmt.Errorf("timeout must be a duration string such as \"10m\"")
+	}
+
+	out, err := time.ParseDuration(string(val))
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout, %s", err)
+	}
+
+	if out <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+
+	return out, nil
+}
+
+// parseSteps returns elements of "steps", or nil when it does not exist.
+func parseSteps(got []byte) ([]Step, error) {
+	if _, typ, _, _ := jsonparser.Get(got, "steps"); typ == jsonparser.NotExist {
+		return nil, nil
+	}
+
+	out := []Step{}
+
+	var failed error
+
+	_, err := jsonparser.ArrayEach(got, func(val []byte, typ jsonparser.ValueType, _ int, err error) {
+		if failed != nil {
+			return
+		}
+
+		if err != nil || typ != jsonparser.Object {
+			failed = fmt.Errorf("step %d must be an object", len(out)+1)
+			return
+		}
+
+		admin, err := extract(val, "adminCommand")
+
+		if err != nil {
+			failed = err
+			return
+		}
+
+		general, err := extract(val, "command")
+
+		if err != nil {
+			failed = err
+			return
+		}
+
+		desc, err := extract(val, "description")
+
+		if err != nil {
+			failed = err
+			return
+		}
+
+		switch {
+		case admin != "" && general != "":
+			failed = fmt.Errorf("step %d must contain either adminCommand or command, not both", len(out)+1)
+		case admin != "":
+			out = append(out, Step{Kind: StepAdmin, Raw: admin, Description: desc})
+		case general != "":
+			out = append(out, Step{Kind: StepCommand, Raw: general, Description: desc})
+		default:
+			failed = fmt.Errorf("step %d must contain either adminCommand or command", len(out)+1)
+		}
+	}, "steps")
+
+	if err != nil {
+		return nil, fmt.Errorf("steps must be an array, %s", err)
+	}
+
+	if failed != nil {
+		return nil, failed
+	}
+
+	return out, nil
+}
+
+// extract returns string representation of the given key, or zero string when it does not exist.
+func extract(got []byte, key string) (string, error) {
+	val, typ, _, err := jsonparser.Get(got, key)
+
+	if err != nil {
+		// Skip when schema may not contain the key.
+		if typ != jsonparser.NotExist {
+			return "", err
+		}
+	}
+
+	if typ == jsonparser.NotExist {
+		return "", nil
+	}
+
+	return string(val), nil
+}
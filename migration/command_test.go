@@ -0,0 +1,211 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCommand(t *testing.T) {
+	// OK
+	func() {
+		got, err := parseCommand(os.DirFS(".."), "examples/000000001_users.json", "demo")
+
+		if err != nil || got == nil {
+			t.Errorf("should pass, error %s", err)
+			return
+		}
+
+		if got.Version == "" {
+			t.Errorf("must pass")
+			return
+		}
+
+		if got.Admin == "" || got.General == "" {
+			t.Errorf("must pass, %#v", got)
+			return
+		}
+	}()
+
+	// OK when adminCommand does not exist.
+	func() {
+		got, err := parseCommand(os.DirFS(".."), "examples/000000004-admin-only.json", "demo")
+
+		if err != nil || got == nil {
+			t.Errorf("should pass, error %s", err)
+			return
+		}
+
+		if got.Version == "" {
+			t.Errorf("must pass")
+			return
+		}
+
+		if got.Admin == "" || got.General != "" {
+			t.Errorf("must pass")
+			return
+		}
+	}()
+
+	// OK when command does not exist.
+	func() {
+		got, err := parseCommand(os.DirFS(".."), "examples/000000005-command-only.json", "demo")
+
+		if err != nil || got == nil {
+			t.Errorf("should pass, error %s", err)
+			return
+		}
+
+		if got.Version == "" {
+			t.Errorf("must pass")
+			return
+		}
+
+		if got.Admin != "" || got.General == "" {
+			t.Errorf("must pass")
+			return
+		}
+	}()
+
+	// OK with down commands.
+	func() {
+		got, err := parseCommand(os.DirFS(".."), "examples/down/000000002_admins.json", "demo")
+
+		if err != nil || got == nil {
+			t.Errorf("should pass, error %s", err)
+			return
+		}
+
+		if got.DownGeneral == "" || got.DownAdmin != "" || !got.HasDown() {
+			t.Errorf("must pass, %#v", got)
+			return
+		}
+	}()
+}
+
+func TestSteps(t *testing.T) {
+	got, err := parseCommand(os.DirFS(".."), "examples/000000001_users.json", "demo")
+
+	if err != nil {
+		t.Errorf("should pass, error %s", err)
+		return
+	}
+
+	steps := got.Steps()
+
+	if len(steps) != 2 || steps[0].Kind != StepAdmin || steps[1].Kind != StepCommand {
+		t.Errorf("admin should run before command, got %#v", steps)
+	}
+
+	got, err = parseCommand(os.DirFS(".."), "examples/000000005-command-only.json", "demo")
+
+	if err != nil {
+		t.Errorf("should pass, error %s", err)
+		return
+	}
+
+	if steps := got.Steps(); len(steps) != 1 || steps[0].Kind != StepCommand {
+		t.Errorf("should contain command only, got %#v", steps)
+	}
+}
+
+func TestParseCommandSteps(t *testing.T) {
+	// OK with steps.
+	func() {
+		got, err := parseCommand(os.DirFS(".."), "examples/000000006-steps.json", "demo")
+
+		if err != nil || got == nil {
+			t.Errorf("should pass, error %s", err)
+			return
+		}
+
+		steps := got.Steps()
+
+		if len(steps) != 3 {
+			t.Errorf("should contain 3 steps, got %#v", steps)
+			return
+		}
+
+		if steps[0].Kind != StepAdmin || steps[0].Description != "shard orders collection" {
+			t.Errorf("must pass, %#v", steps[0])
+		}
+
+		if !strings.Contains(steps[0].Raw, "demo.orders") {
+			t.Errorf("should substitute database name, %#v", steps[0])
+		}
+
+		if steps[2].Kind != StepCommand || steps[2].Description != "" {
+			t.Errorf("must pass, %#v", steps[2])
+		}
+	}()
+
+	// Fails on invalid steps.
+	func() {
+		dir := t.TempDir()
+
+		patterns := []string{
+			`{"steps": [{"adminCommand": {"a": 1}, "command": {"b": 1}}]}`,
+			`{"steps": [{"description": "nothing"}]}`,
+			`{"steps": ["text"]}`,
+			`{"steps": {}}`,
+			`{"steps": [], "command": {"b": 1}}`,
+		}
+
+		for idx, p := range patterns {
+			name := fmt.Sprintf("%d.json", idx)
+
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(p), 0o644); err != nil {
+				t.Errorf("should not fail, error %s", err)
+				return
+			}
+
+			if got, err := parseCommand(os.DirFS(dir), name, "demo"); err == nil {
+				t.Errorf("case %d should fail, got %#v", idx, got)
+			}
+		}
+	}()
+}
+
+func TestParseCommandTimeout(t *testing.T) {
+	type pattern struct {
+		given string
+		exp   time.Duration
+		fails bool
+	}
+
+	pats := []pattern{
+		{`{"command": {"a": 1}}`, 0, false},
+		{`{"command": {"a": 1}, "timeout": "10m"}`, 10 * time.Minute, false},
+		{`{"steps": [{"command": {"a": 1}}], "timeout": "90s"}`, 90 * time.Second, false},
+		{`{"command": {"a": 1}, "timeout": 600}`, 0, true},
+		{`{"command": {"a": 1}, "timeout": "ten minutes"}`, 0, true},
+		{`{"command": {"a": 1}, "timeout": "-1s"}`, 0, true},
+	}
+
+	dir := t.TempDir()
+
+	for idx, p := range pats {
+		name := fmt.Sprintf("%d.json", idx)
+
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(p.given), 0o644); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		got, err := parseCommand(os.DirFS(dir), name, "demo")
+
+		if p.fails {
+			if err == nil {
+				t.Errorf("case %d should fail, got %#v", idx, got)
+			}
+			continue
+		}
+
+		if err != nil || got.Timeout != p.exp {
+			t.Errorf("case %d expected %s, got %#v, error %s", idx, p.exp, got, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// Rollback represents a single file to be rolled back by down.
+type Rollback struct {
+	Command *Command
+	// Previous is the version the pointer moves back to.
+	Previous string
+}
+
+/*
+PlanDown returns files to roll back, newest first.
+Either steps or to can be given, steps is 1 when neither is given.
+Files which have never been applied are left out, and are not counted as steps.
+
+ロールバック対象のファイルを新しい順に返します。
+steps と to のどちらかを指定します。どちらも指定しない場合は 1 ファイルのみ対象とします。
+一度も適用されていないファイルは対象外とし、steps にも数えません。
+*/
+func (m *Migrator) PlanDown(ctx context.Context, steps int, to string) ([]Rollback, error) {
+	if steps != 0 && to != "" {
+		return nil, fmt.Errorf("steps and to cannot be specified at the same time")
+	}
+
+	if steps < 0 {
+		return nil, fmt.Errorf("steps must be positive")
+	}
+
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cur, ok := latest(entries)
+
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve migration history")
+	}
+
+	paths, err := m.listFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return planDown(m.fsys, paths, cur, entries, steps, to, m.db.Name())
+}
+
+func planDown(fsys fs.FS, paths []string, cur string, entries []Entry, steps int, to, dbname string) ([]Rollback, error) {
+	if cur == migrationInitValue {
+		return nil, nil
+	}
+
+	idx := indexOf(paths, cur)
+
+	if idx < 0 {
+		return nil, fmt.Errorf("current version %s does not exist in the directory", cur)
+	}
+
+	applied, floor := appliedVersions(entries)
+
+	// Positions of applied files up to current, newest first.
+	var targets []int
+
+	for i := idx; i >= 0; i-- {
+		if isApplied(applied, floor, filename(paths[i])) {
+			targets = append(targets, i)
+		}
+	}
+
+	// Applied files after stop, up to current, are rolled back.
+	stop := -1
+
+	if len(targets) > 1 {
+		stop = targets[1]
+	}
+
+	switch {
+	case to == migrationInitValue:
+		stop = -1
+	case to != "":
+		stop = indexOf(paths, to)
+
+		if stop < 0 {
+			return nil, fmt.Errorf("target version %s does not exist in the directory", to)
+		}
+
+		if stop > idx {
+			return nil, fmt.Errorf("target version %s is ahead of current version %s", to, cur)
+		}
+	case steps > 0:
+		stop = -1
+
+		if steps < len(targets) {
+			stop = targets[steps]
+		}
+	}
+
+	var out []Rollback
+
+	for n, i := range targets {
+		if i <= stop {
+			break
+		}
+
+		cmd, err := parseCommand(fsys, paths[i], dbname)
+
+		if err != nil || cmd == nil {
+			return nil, fmt.Errorf("failed to parse JSON, schema is possibly broken, %s", err)
+		}
+
+		if !cmd.HasDown() {
+			return nil, fmt.Errorf("%s does not contain downCommand nor downAdminCommand", cmd.Version)
+		}
+
+		// The pointer moves back to the previous applied file, not to a skipped one.
+		prev := migrationInitValue
+
+		if n+1 < len(targets) {
+			prev = filename(paths[targets[n+1]])
+		}
+
+		out = append(out, Rollback{Command: cmd, Previous: prev})
+	}
+
+	return out, nil
+}
+
+// indexOf returns position of the version within paths, or -1.
+func indexOf(paths []string, version string) int {
+	for i, p := range paths {
+		if filename(p) == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+Down rolls back applied files while holding the lock.
+It stops with ErrLockLost before the next file once the lock has been lost.
+
+ロックを保持した状態で、適用済みのファイルをロールバックします。
+*/
+func (m *Migrator) Down(ctx context.Context, steps int, to string) error {
+	if err := m.opts.Provider.validate(); err != nil {
+		return err
+	}
+
+	lease, err := m.AcquireLock(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Release even after ctx is done.
+		if err := lease.Release(context.WithoutCancel(ctx)); err != nil {
+			m.log.Printf("warning: %s", err)
+		}
+	}()
+
+	if err := m.upgradeHistory(ctx); err != nil {
+		return err
+	}
+
+	plan, err := m.PlanDown(ctx, steps, to)
+
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		m.log.Printf("nothing to roll back")
+		return nil
+	}
+
+	for _, r := range plan {
+		if ctx.Err() != nil {
+			m.log.Printf("interrupted before rolling back %s", r.Command.Version)
+			return ErrInterrupted
+		}
+
+		if err := lease.Err(); err != nil {
+			return err
+		}
+
+		m.log.Printf("rolling back %s", r.Command.Version)
+
+		if err := m.ApplyDown(ctx, r); err != nil {
+			return err
+		}
+
+		m.log.Printf("rolled back %s, current version is %s", r.Command.Version, r.Previous)
+	}
+
+	return nil
+}
+
+/*
+ApplyDown rolls back the given file, and moves the pointer to the previous version.
+
+ファイルをロールバックし、マイグレーションポインタを一つ前のバージョンに戻します。
+*/
+func (m *Migrator) ApplyDown(ctx context.Context, r Rollback) error {
+	if r.Command == nil {
+		return fmt.Errorf("invalid command given")
+	}
+
+	// Down commands cannot resume halfway, so cancellation is not propagated to a file once started.
+	ctx = context.WithoutCancel(ctx)
+	started := time.Now()
+
+	if err := m.executeDown(ctx, r.Command); err != nil {
+		m.emit(Event{Phase: PhaseFailed, Version: r.Command.Version, DurationMs: time.Since(started).Milliseconds(), Error: err.Error()})
+
+		e := newEntry(r.Command.Version, StatusRollbackFailed)
+		e.DurationMs = time.Since(started).Milliseconds()
+		e.Checksum = r.Command.Checksum
+		e.Error = err.Error()
+
+		if rerr := m.record(ctx, e); rerr != nil {
+			return fmt.Errorf("%w, %s", err, rerr)
+		}
+
+		return err
+	}
+
+	e := newEntry(r.Command.Version, StatusRolledBack)
+	e.DurationMs = time.Since(started).Milliseconds()
+	e.Checksum = r.Command.Checksum
+	e.Pointer = r.Previous
+
+	m.emit(Event{Phase: PhaseCompleted, Version: r.Command.Version, DurationMs: e.DurationMs})
+
+	return m.record(ctx, e)
+}
+
+// executeDown runs down commands in reverse order of execute.
+func (m *Migrator) executeDown(ctx context.Context, in *Command) error {
+	// Run user command (optional)
+	if in.DownGeneral != "" {
+		if _, err := m.runStep(ctx, in, Step{Kind: StepCommand, Raw: in.DownGeneral}, Delete); err != nil {
+			return err
+		}
+	}
+
+	// Run admin command (optional)
+	if in.DownAdmin != "" {
+		if _, err := m.runStep(ctx, in, Step{Kind: StepAdmin, Raw: in.DownAdmin}, Delete); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"testing"
+)
+
+func TestPlanDown(t *testing.T) {
+	m := newTestMigrator("demo", Options{Dir: "../examples/down"})
+
+	paths, err := m.listFiles()
+
+	if err != nil || len(paths) != 3 {
+		t.Errorf("should not fail, got %v, error %s", paths, err)
+		return
+	}
+
+	first := "000000001_users.json"
+	second := "000000002_admins.json"
+	third := "000000003_no_down.json"
+
+	// Every file is applied.
+	all := []Entry{{Version: third, Status: StatusBaseline}}
+
+	// OK with single step by default.
+	func() {
+		got, err := planDown(m.fsys, paths, second, all, 0, "", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(got) != 1 || got[0].Command.Version != second || got[0].Previous != first {
+			t.Errorf("should roll back a single file, got %#v", got)
+		}
+	}()
+
+	// OK with steps beyond the first file.
+	func() {
+		got, err := planDown(m.fsys, paths, second, all, 5, "", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(got) != 2 || got[1].Command.Version != first || got[1].Previous != migrationInitValue {
+			t.Errorf("should roll back everything, got %#v", got)
+		}
+	}()
+
+	// OK with target version.
+	func() {
+		got, err := planDown(m.fsys, paths, second, all, 0, first, "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(got) != 1 || got[0].Command.Version != second {
+			t.Errorf("should roll back until target, got %#v", got)
+		}
+	}()
+
+	// OK with nothing applied.
+	func() {
+		got, err := planDown(m.fsys, paths, migrationInitValue, all, 1, "", "demo")
+
+		if err != nil || len(got) != 0 {
+			t.Errorf("should be empty, got %#v, error %s", got, err)
+		}
+	}()
+
+	// OK with skipped file inside the range, which is neither rolled back nor counted as a step.
+	func() {
+		// First file has been merged after second one was applied.
+		entries := []Entry{{Version: second, Status: StatusApplied}}
+
+		got, err := planDown(m.fsys, paths, second, entries, 5, "", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(got) != 1 || got[0].Command.Version != second || got[0].Previous != migrationInitValue {
+			t.Errorf("should skip the file never applied, got %#v", got)
+		}
+	}()
+
+	// Fails when file has no down command.
+	func() {
+		if _, err := planDown(m.fsys, paths, third, all, 1, "", "demo"); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+
+	// Fails when target is ahead of current.
+	func() {
+		if _, err := planDown(m.fsys, paths, first, all, 0, second, "demo"); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+
+	// Fails when current does not exist.
+	func() {
+		if _, err := planDown(m.fsys, paths, "000000009_unknown.json", all, 1, "", "demo"); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
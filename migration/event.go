@@ -0,0 +1,54 @@
+package migration
+
+import "time"
+
+// Phase names a point of progress reported as Event.
+type Phase string
+
+const (
+	// PhaseConnect is reported by callers once connected to the database.
+	PhaseConnect Phase = "connect"
+	// PhaseCurrent reports the current version.
+	PhaseCurrent Phase = "current"
+	// PhaseNext reports the file to apply next, Version is empty when nothing is left.
+	PhaseNext Phase = "next"
+	// PhaseAdmin reports an admin step has run.
+	PhaseAdmin Phase = StepAdmin
+	// PhaseCommand reports a general command step has run.
+	PhaseCommand Phase = StepCommand
+	// PhaseCompleted reports a file has been applied or rolled back.
+	PhaseCompleted Phase = "completed"
+	// PhaseFailed reports a file has failed.
+	PhaseFailed Phase = "failed"
+	// PhaseInterrupted reports a file has been stopped by cancellation.
+	PhaseInterrupted Phase = "interrupted"
+	// PhaseModified reports an applied file has changed since then.
+	PhaseModified Phase = "modified"
+	// PhaseStatus is reported by callers along with StatusReport.
+	PhaseStatus Phase = "status"
+)
+
+// Event represents a structured progress report, given to Options.OnEvent.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Phase   Phase     `json:"phase"`
+	Version string    `json:"version,omitempty"`
+	// Step is the position within Steps of the file counted from 1, zero unless the event is about a step.
+	Step       int    `json:"step,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Summary    string `json:"summary,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// emit reports the event to Options.OnEvent when given.
+func (m *Migrator) emit(e Event) {
+	if m.opts.OnEvent == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	m.opts.OnEvent(e)
+}
@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"testing"
+)
+
+func TestEmit(t *testing.T) {
+	var got []Event
+
+	m := newTestMigrator("demo", Options{OnEvent: func(e Event) { got = append(got, e) }})
+
+	m.emit(Event{Phase: PhaseCurrent, Version: "000000001_users.json"})
+
+	if len(got) != 1 || got[0].Phase != PhaseCurrent || got[0].Time.IsZero() {
+		t.Errorf("should be reported with time, got %#v", got)
+	}
+
+	// OK without handler.
+	func() {
+		newTestMigrator("demo", Options{}).emit(Event{Phase: PhaseCurrent})
+	}()
+}
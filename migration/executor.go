@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Executor runs arguments of az built by AzureCommand.CreateCommand, and returns output of az.
+// AzCLI, *ARMClient, *Recorder and *Printer implement it.
+type Executor interface {
+	Execute(ctx context.Context, args []string) ([]byte, error)
+}
+
+// AzCLI runs az installed on the machine.
+type AzCLI struct{}
+
+// Execute runs az with the arguments, error contains stderr of az.
+func (AzCLI) Execute(ctx context.Context, args []string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "az", args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+
+	var exit *exec.ExitError
+
+	if errors.As(err, &exit) {
+		return out, fmt.Errorf("az %s failed with %w, %s", strings.Join(azCommand(args), " "), exit, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, err
+}
+
+// azCommand returns the command part of the arguments, e.g. "cosmosdb mongodb collection create".
+func azCommand(args []string) []string {
+	for i, a := range args {
+		if strings.HasPrefix(a, "-") {
+			return args[:i]
+		}
+	}
+
+	return args
+}
+
+// Recorder records arguments instead of running them, so the Azure path can be tested offline.
+type Recorder struct {
+	// Reply returns output and error for the arguments, nothing is returned when nil.
+	Reply func(args []string) ([]byte, error)
+
+	mu    sync.Mutex
+	calls [][]string
+}
+
+// Execute records the arguments and returns Reply.
+func (r *Recorder) Execute(ctx context.Context, args []string) ([]byte, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, append([]string(nil), args...))
+	r.mu.Unlock()
+
+	if r.Reply == nil {
+		return nil, nil
+	}
+
+	return r.Reply(args)
+}
+
+// Calls returns arguments given so far in order.
+func (r *Recorder) Calls() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([][]string(nil), r.calls...)
+}
+
+// Printer writes az command lines instead of running them.
+type Printer struct {
+	// W receives the command lines, os.Stdout by default.
+	W io.Writer
+}
+
+// Execute writes the arguments as a command line, and returns nothing.
+func (p *Printer) Execute(ctx context.Context, args []string) ([]byte, error) {
+	w := p.W
+
+	if w == nil {
+		w = os.Stdout
+	}
+
+	_, err := fmt.Fprintf(w, "az %s\n", strings.Join(args, " "))
+
+	return nil, err
+}
@@ -0,0 +1,163 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	r := &Recorder{
+		Reply: func(args []string) ([]byte, error) {
+			if args[3] == "exists" {
+				return []byte("true"), nil
+			}
+
+			return nil, errors.New("failed")
+		},
+	}
+
+	args := []string{"cosmosdb", "mongodb", "collection", "exists", "-n", "users"}
+
+	out, err := r.Execute(context.Background(), args)
+
+	if err != nil || string(out) != "true" {
+		t.Errorf("should reply, got %s, error %s", out, err)
+	}
+
+	// Recorded arguments are not affected by the caller.
+	args[5] = "orders"
+
+	if _, err := r.Execute(context.Background(), []string{"cosmosdb", "mongodb", "collection", "delete"}); err == nil {
+		t.Errorf("should fail")
+	}
+
+	calls := r.Calls()
+
+	if len(calls) != 2 || calls[0][5] != "users" || calls[1][3] != "delete" {
+		t.Errorf("should record every call, got %v", calls)
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := &Printer{W: &buf}
+
+	out, err := p.Execute(context.Background(), []string{"cosmosdb", "mongodb", "collection", "show", "-n", "users"})
+
+	if err != nil || out != nil {
+		t.Errorf("should print only, got %s, error %s", out, err)
+	}
+
+	if buf.String() != "az cosmosdb mongodb collection show -n users\n" {
+		t.Errorf("should print command line, got %s", buf.String())
+	}
+
+	// OK without writer, which prints to stdout.
+	if _, err := (&Printer{}).Execute(context.Background(), []string{"cosmosdb", "mongodb", "collection", "list"}); err != nil {
+		t.Errorf("should not fail, error %s", err)
+	}
+}
+
+func TestAzCLI(t *testing.T) {
+	// Stand-in of az, which fails with stderr on create.
+	dir := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$4\" = create ]; then echo 'ERROR: (TooManyRequests) RetryAfterMs=20' >&2; exit 1; fi\necho '{\"name\": \"users\"}'\n"
+
+	if err := os.WriteFile(filepath.Join(dir, "az"), []byte(script), 0o755); err != nil {
+		t.Fatalf("should not fail, error %s", err)
+	}
+
+	t.Setenv("PATH", dir)
+
+	ctx := context.Background()
+
+	// OK with output.
+	func() {
+		out, err := AzCLI{}.Execute(ctx, []string{"cosmosdb", "mongodb", "collection", "show", "-n", "users"})
+
+		if err != nil || !strings.Contains(string(out), "users") {
+			t.Errorf("should return output, got %s, error %s", out, err)
+		}
+	}()
+
+	// Fails with stderr, which tells throttling.
+	func() {
+		_, err := AzCLI{}.Execute(ctx, []string{"cosmosdb", "mongodb", "collection", "create", "-n", "users"})
+
+		if err == nil || !strings.Contains(err.Error(), "az cosmosdb mongodb collection create failed with exit status 1, ERROR: (TooManyRequests)") {
+			t.Errorf("should contain command and stderr, error %s", err)
+			return
+		}
+
+		var exit *exec.ExitError
+
+		if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+			t.Errorf("should wrap exit error, error %s", err)
+		}
+
+		if hint, ok := throttled(err); !ok || hint.Milliseconds() != 20 {
+			t.Errorf("should be throttled, got %s", hint)
+		}
+	}()
+}
+
+func TestUpWithRecorder(t *testing.T) {
+	ctx := context.Background()
+	r := &Recorder{}
+
+	m := newTestMigrator("test-executor", Options{
+		Dir:           "../examples-v2",
+		ResourceGroup: "develop",
+		AccountName:   "account",
+		Interval:      -1,
+		Executor:      r,
+	})
+
+	// Setup
+	func() {
+		if err := m.collection().Drop(ctx); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+
+		if err := m.Init(ctx); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+	}()
+
+	if err := m.Up(ctx, UpOptions{}); err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	var created []string
+
+	// Each admin command checks existence before create.
+	for _, c := range r.Calls() {
+		if c[3] == "create" {
+			created = append(created, strings.Join(c, " "))
+		}
+	}
+
+	if len(created) != 4 {
+		t.Errorf("should run every admin command through executor, got %v", r.Calls())
+		return
+	}
+
+	exp := "cosmosdb mongodb collection create -g develop -a account -d test-executor -n users --shard _id --max-throughput 4000"
+
+	if created[0] != exp {
+		t.Errorf("should be identical, got %s", created[0])
+	}
+
+	if got, err := m.Current(ctx); err != nil || got != "000000003_steps.json" {
+		t.Errorf("should apply every file, got %s, error %s", got, err)
+	}
+}
@@ -0,0 +1,329 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status represents outcome of a history entry.
+type Status string
+
+const (
+	// StatusInit is recorded by Setup.
+	StatusInit Status = "init"
+	// StatusApplied is recorded when a file has been applied.
+	StatusApplied Status = "applied"
+	// StatusFailed is recorded when a file has failed to apply.
+	StatusFailed Status = "failed"
+	// StatusReverted is recorded when the pointer is moved by Revert.
+	StatusReverted Status = "reverted"
+	// StatusBaseline is recorded when a legacy "latest" document is converted.
+	StatusBaseline Status = "baseline"
+	// StatusRepaired is recorded when a changed checksum is accepted by Repair.
+	StatusRepaired Status = "repaired"
+	// StatusRolledBack is recorded when a file has been rolled back by down.
+	StatusRolledBack Status = "rolledback"
+	// StatusRollbackFailed is recorded when a file has failed to roll back.
+	StatusRollbackFailed Status = "rollbackfailed"
+	// StatusInterrupted is recorded when a file has been stopped by cancellation between steps.
+	StatusInterrupted Status = "interrupted"
+)
+
+// sequenceID is the _id of the counter ordering history entries within the migration collection.
+const sequenceID = "sequence"
+
+// historyKey is the field every history entry has, used to tell them apart
+// from other documents in the migration collection.
+const historyKey = "version"
+
+// Entry represents a single record of migration history.
+type Entry struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Seq            int64              `bson:"seq,omitempty" json:"seq,omitempty"`
+	Version        string             `bson:"version" json:"version"`
+	Status         Status             `bson:"status" json:"status"`
+	AppliedAt      time.Time          `bson:"appliedAt" json:"appliedAt"`
+	DurationMs     int64              `bson:"durationMs" json:"durationMs"`
+	ToolVersion    string             `bson:"toolVersion" json:"toolVersion"`
+	Host           string             `bson:"host" json:"host"`
+	Checksum       string             `bson:"checksum,omitempty" json:"checksum,omitempty"`
+	Pointer        string             `bson:"pointer,omitempty" json:"pointer,omitempty"`
+	CompletedSteps int                `bson:"completedSteps,omitempty" json:"completedSteps,omitempty"`
+	Responses      []Response         `bson:"responses,omitempty" json:"responses,omitempty"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// movesPointer reports whether the entry changes current migration state.
+func (e Entry) movesPointer() bool {
+	switch e.Status {
+	case StatusInit, StatusApplied, StatusReverted, StatusBaseline, StatusRolledBack:
+		return true
+	default:
+		return false
+	}
+}
+
+func newEntry(version string, status Status) Entry {
+	host, _ := os.Hostname()
+
+	return Entry{
+		Version:     version,
+		Status:      status,
+		AppliedAt:   time.Now().UTC(),
+		ToolVersion: Version,
+		Host:        host,
+	}
+}
+
+/*
+History returns every recorded entry, oldest first.
+A document written by older versions is returned as a baseline entry without converting it,
+so reading history never writes to the database.
+
+マイグレーション履歴を古い順にすべて返します。
+*/
+func (m *Migrator) History(ctx context.Context) ([]Entry, error) {
+	legacy, _, err := m.legacyEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	q := bson.D{{Key: historyKey, Value: bson.D{{Key: "$exists", Value: true}}}}
+
+	cursor, err := m.collection().Find(c, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve migration history, %s", err)
+	}
+
+	var entries []Entry
+
+	if err := cursor.All(c, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode migration history, %s", err)
+	}
+
+	// Sort on client side, Cosmos DB refuses to sort on fields without index.
+	sortEntries(entries)
+
+	// Legacy document precedes every entry, as it is converted before anything else is recorded.
+	if legacy != nil {
+		entries = append([]Entry{*legacy}, entries...)
+	}
+
+	return entries, nil
+}
+
+// sortEntries sorts by Seq, which is counted by the database so clocks of hosts do not matter,
+// and by appliedAt among entries without Seq.
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Seq != entries[j].Seq {
+			return entries[i].Seq < entries[j].Seq
+		}
+
+		if !entries[i].AppliedAt.Equal(entries[j].AppliedAt) {
+			return entries[i].AppliedAt.Before(entries[j].AppliedAt)
+		}
+
+		return entries[i].ID.Hex() < entries[j].ID.Hex()
+	})
+}
+
+// pointer returns the version current state moves to by the entry.
+// Pointer is only set when it differs from Version, e.g. on rollback.
+func (e Entry) pointer() string {
+	if e.Pointer != "" {
+		return e.Pointer
+	}
+
+	return e.Version
+}
+
+// latest returns the version pointed by the last entry which moved the pointer.
+func latest(entries []Entry) (string, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].movesPointer() {
+			return entries[i].pointer(), true
+		}
+	}
+
+	return "", false
+}
+
+func (m *Migrator) record(ctx context.Context, e Entry) error {
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	err := m.withRetry(c, "migration history", func() error {
+		if e.Seq == 0 {
+			seq, err := m.nextSeq(c)
+
+			if err != nil {
+				return err
+			}
+
+			e.Seq = seq
+		}
+
+		_, err := m.collection().InsertOne(c, e)
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to record migration history, %s", err)
+	}
+
+	return nil
+}
+
+// nextSeq increments the counter on the database, and returns the new value.
+func (m *Migrator) nextSeq(ctx context.Context) (int64, error) {
+	var out struct {
+		Value int64 `bson:"value"`
+	}
+
+	q := bson.D{{Key: "_id", Value: sequenceID}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "value", Value: int64(1)}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	if err := m.collection().FindOneAndUpdate(ctx, q, update, opts).Decode(&out); err != nil {
+		return 0, err
+	}
+
+	return out.Value, nil
+}
+
+// legacyEntry returns the document written by older versions, which only held the "latest" filename,
+// as a baseline entry along with its _id, nil when it does not exist.
+func (m *Migrator) legacyEntry(ctx context.Context) (*Entry, any, error) {
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	var legacy bson.M
+
+	q := bson.D{{Key: legacyMigrationKey, Value: bson.D{{Key: "$exists", Value: true}}}}
+
+	err := m.collection().FindOne(c, q).Decode(&legacy)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lookup legacy migration key, %s", err)
+	}
+
+	version, ok := legacy[legacyMigrationKey].(string)
+
+	if !ok {
+		return nil, nil, fmt.Errorf("legacy migration key is not a string, %v", legacy[legacyMigrationKey])
+	}
+
+	status := StatusBaseline
+
+	if version == migrationInitValue {
+		status = StatusInit
+	}
+
+	e := newEntry(version, status)
+
+	return &e, legacy["_id"], nil
+}
+
+/*
+upgradeHistory converts a document written by older versions into a baseline history entry.
+It writes to the database, so it is called only while holding the lock.
+
+旧バージョンの "latest" ドキュメントを履歴エントリに変換します。
+*/
+func (m *Migrator) upgradeHistory(ctx context.Context) error {
+	legacy, id, err := m.legacyEntry(ctx)
+
+	if err != nil || legacy == nil {
+		return err
+	}
+
+	// Insert first, so the pointer is never lost even if delete fails.
+	if err := m.record(ctx, *legacy); err != nil {
+		return err
+	}
+
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.collection().DeleteOne(c, bson.D{{Key: "_id", Value: id}}); err != nil {
+		return fmt.Errorf("failed to remove legacy migration key, %s", err)
+	}
+
+	return nil
+}
+
+/*
+appliedVersions replays history and returns versions considered as applied.
+Baseline and reverted entries only know the pointer, so every version up to
+the returned floor is considered as applied as well.
+
+履歴を再生し、適用済みとみなすバージョンを返します。
+*/
+func appliedVersions(entries []Entry) (map[string]bool, string) {
+	applied := map[string]bool{}
+	floor := migrationInitValue
+
+	for _, e := range entries {
+		switch e.Status {
+		case StatusInit:
+			applied = map[string]bool{}
+			floor = migrationInitValue
+		case StatusApplied:
+			applied[e.Version] = true
+		case StatusBaseline, StatusReverted:
+			floor = e.Version
+
+			for v := range applied {
+				if v > e.Version {
+					delete(applied, v)
+				}
+			}
+		case StatusRolledBack:
+			delete(applied, e.Version)
+
+			if floor >= e.Version {
+				floor = e.pointer()
+			}
+		}
+	}
+
+	return applied, floor
+}
+
+// resumePoint returns number of steps already completed by the last failed attempt of the file.
+// Nothing is skipped once the file has changed since then.
+func resumePoint(entries []Entry, in *Command) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		if e.Version != in.Version {
+			continue
+		}
+
+		if (e.Status != StatusFailed && e.Status != StatusInterrupted) || e.Checksum != in.Checksum {
+			return 0
+		}
+
+		return e.CompletedSteps
+	}
+
+	return 0
+}
@@ -0,0 +1,229 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLatest(t *testing.T) {
+	now := time.Now()
+
+	type pattern struct {
+		entries []Entry
+		exp     string
+		ok      bool
+	}
+
+	pats := []pattern{
+		{nil, "", false},
+		{[]Entry{{Version: "0", Status: StatusInit, AppliedAt: now}}, "0", true},
+		{
+			[]Entry{
+				{Version: "0", Status: StatusInit, AppliedAt: now},
+				{Version: "000000001_users.json", Status: StatusApplied, AppliedAt: now.Add(time.Second)},
+				{Version: "000000002_admins.json", Status: StatusFailed, AppliedAt: now.Add(2 * time.Second)},
+			},
+			"000000001_users.json",
+			true,
+		},
+		{
+			[]Entry{
+				{Version: "000000002_admins.json", Status: StatusBaseline, AppliedAt: now},
+				{Version: "000000001_users.json", Status: StatusReverted, AppliedAt: now.Add(time.Second)},
+			},
+			"000000001_users.json",
+			true,
+		},
+		{
+			[]Entry{
+				{Version: "000000001_users.json", Status: StatusApplied, AppliedAt: now},
+				{Version: "000000001_users.json", Status: StatusRolledBack, Pointer: "0", AppliedAt: now.Add(time.Second)},
+			},
+			"0",
+			true,
+		},
+	}
+
+	for idx, p := range pats {
+		got, ok := latest(p.entries)
+
+		if got != p.exp || ok != p.ok {
+			t.Errorf("case %d expected %s (%v), got %s (%v)", idx, p.exp, p.ok, got, ok)
+		}
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	now := time.Now()
+
+	entries := []Entry{
+		{Version: "second", AppliedAt: now.Add(time.Second)},
+		{Version: "first", AppliedAt: now},
+	}
+
+	sortEntries(entries)
+
+	if entries[0].Version != "first" || entries[1].Version != "second" {
+		t.Errorf("should be sorted by appliedAt, got %#v", entries)
+	}
+
+	// Seq wins over appliedAt, which may be skewed among hosts.
+	skewed := []Entry{
+		{Version: "second", Seq: 2, AppliedAt: now},
+		{Version: "first", Seq: 1, AppliedAt: now.Add(time.Minute)},
+	}
+
+	sortEntries(skewed)
+
+	if skewed[0].Version != "first" || skewed[1].Version != "second" {
+		t.Errorf("should be sorted by seq, got %#v", skewed)
+	}
+}
+
+func TestRecordSequence(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator("demo", Options{})
+
+	// Setup
+	func() {
+		if err := m.collection().Drop(ctx); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+	}()
+
+	// The second host has its clock behind the first one.
+	first := newEntry("000000001_users.json", StatusApplied)
+	second := newEntry("000000002_admins.json", StatusApplied)
+	second.AppliedAt = first.AppliedAt.Add(-time.Hour)
+
+	for _, e := range []Entry{first, second} {
+		if err := m.record(ctx, e); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+	}
+
+	entries, err := m.History(ctx)
+
+	if err != nil || len(entries) != 2 || entries[0].Seq != 1 || entries[1].Seq != 2 {
+		t.Errorf("should be numbered in recorded order, got %#v, error %s", entries, err)
+	}
+
+	if got, err := m.Current(ctx); err != nil || got != "000000002_admins.json" {
+		t.Errorf("should be the last recorded, got %s, error %s", got, err)
+	}
+}
+
+func TestUpgradeHistory(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator("demo", Options{})
+
+	// Setup
+	func() {
+		if err := m.collection().Drop(ctx); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+
+		legacy := bson.D{{Key: legacyMigrationKey, Value: "000000002_admins.json"}}
+
+		if _, err := m.collection().InsertOne(ctx, legacy); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+	}()
+
+	// Legacy pointer is kept as current.
+	func() {
+		got, err := m.Current(ctx)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if got != "000000002_admins.json" {
+			t.Errorf("should be identical, got %s", got)
+		}
+	}()
+
+	q := bson.D{{Key: legacyMigrationKey, Value: bson.D{{Key: "$exists", Value: true}}}}
+
+	// Legacy document is read as a single entry without writing.
+	func() {
+		entries, err := m.History(ctx)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(entries) != 1 || entries[0].Status != StatusBaseline {
+			t.Errorf("should be read as baseline, got %#v", entries)
+		}
+
+		n, err := m.collection().CountDocuments(ctx, bson.D{})
+
+		if err != nil || n != 1 {
+			t.Errorf("should not write, count %d, error %s", n, err)
+		}
+	}()
+
+	// Legacy document is converted into a single entry.
+	func() {
+		if err := m.upgradeHistory(ctx); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		entries, err := m.History(ctx)
+
+		if err != nil || len(entries) != 1 || entries[0].Status != StatusBaseline || entries[0].ID.IsZero() {
+			t.Errorf("should be converted, got %#v, error %s", entries, err)
+		}
+
+		n, err := m.collection().CountDocuments(ctx, q)
+
+		if err != nil || n != 0 {
+			t.Errorf("legacy document should be removed, count %d, error %s", n, err)
+		}
+	}()
+}
+
+func TestResumePoint(t *testing.T) {
+	in := &Command{Version: "000000001_users.json", Checksum: "a"}
+
+	type pattern struct {
+		entries []Entry
+		exp     int
+	}
+
+	pats := []pattern{
+		{nil, 0},
+		{[]Entry{{Version: in.Version, Status: StatusFailed, Checksum: "a", CompletedSteps: 1}}, 1},
+		{[]Entry{{Version: in.Version, Status: StatusFailed, Checksum: "b", CompletedSteps: 1}}, 0},
+		{[]Entry{{Version: in.Version, Status: StatusInterrupted, Checksum: "a", CompletedSteps: 2}}, 2},
+		{
+			[]Entry{
+				{Version: in.Version, Status: StatusFailed, Checksum: "a", CompletedSteps: 1},
+				{Version: in.Version, Status: StatusApplied, Checksum: "a"},
+			},
+			0,
+		},
+		{
+			[]Entry{
+				{Version: in.Version, Status: StatusFailed, Checksum: "a", CompletedSteps: 1},
+				{Version: "000000002_admins.json", Status: StatusFailed, Checksum: "c"},
+			},
+			1,
+		},
+	}
+
+	for idx, p := range pats {
+		if got := resumePoint(p.entries, in); got != p.exp {
+			t.Errorf("case %d expected %d, got %d", idx, p.exp, got)
+		}
+	}
+}
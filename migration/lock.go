@@ -0,0 +1,259 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lockID is the _id of the lease document within the migration collection.
+const lockID = "lock"
+
+// ErrLockLost is returned when the lease has been taken over or has expired while running migrations.
+var ErrLockLost = errors.New("migration lock has been lost")
+
+// Lock represents the lease document.
+type Lock struct {
+	Owner       string    `bson:"owner"`
+	AcquiredAt  time.Time `bson:"acquiredAt"`
+	HeartbeatAt time.Time `bson:"heartbeatAt"`
+	ExpiresAt   time.Time `bson:"expiresAt"`
+}
+
+// Lease represents a lock held by this process.
+type Lease struct {
+	m       *Migrator
+	owner   string
+	ttl     time.Duration
+	expires time.Time
+	stop    chan struct{}
+	lost    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+}
+
+/*
+AcquireLock takes the lease so only one process can run migrations at a time.
+The lease is extended in background until Release is called.
+
+マイグレーションの同時実行を防ぐためにロックを取得します。
+ロックは Release が呼ばれるまでバックグラウンドで延長されます。
+*/
+func (m *Migrator) AcquireLock(ctx context.Context) (*Lease, error) {
+	ttl := m.opts.LockTTL
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("lock ttl must be positive")
+	}
+
+	owner := lockOwner()
+	now := time.Now().UTC()
+
+	// Matches only when nobody holds the lease, otherwise upsert collides on _id.
+	q := bson.D{
+		{Key: "_id", Value: lockID},
+		{Key: "expiresAt", Value: bson.D{{Key: "$lt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "acquiredAt", Value: now},
+		{Key: "heartbeatAt", Value: now},
+		{Key: "expiresAt", Value: now.Add(ttl)},
+	}}}
+	opts := options.Update().SetUpsert(true)
+
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.collection().UpdateOne(c, q, update, opts); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			if held, err := m.CurrentLock(ctx); err == nil && held != nil {
+				return nil, fmt.Errorf("migration is locked by %s until %s", held.Owner, held.ExpiresAt.Local().Format(time.RFC3339))
+			}
+
+			return nil, fmt.Errorf("migration is locked by another process")
+		}
+
+		return nil, fmt.Errorf("failed to acquire lock, %s", err)
+	}
+
+	l := &Lease{m: m, owner: owner, ttl: ttl, expires: now.Add(ttl), stop: make(chan struct{}), lost: make(chan struct{})}
+
+	l.wg.Add(1)
+	go l.heartbeat()
+
+	return l, nil
+}
+
+func (l *Lease) heartbeat() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			q := bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: l.owner}}
+			update := bson.D{{Key: "$set", Value: bson.D{
+				{Key: "heartbeatAt", Value: now},
+				{Key: "expiresAt", Value: now.Add(l.ttl)},
+			}}}
+
+			c, cancel := l.m.withTimeout(context.Background())
+			res, err := l.m.collection().UpdateOne(c, q, update)
+			cancel()
+
+			if err != nil {
+				// Another process may acquire the lease once it has expired.
+				if !now.Before(l.expires) {
+					l.m.log.Printf("warning: lock has expired, failed to extend lock, %s", err)
+					close(l.lost)
+					return
+				}
+
+				l.m.log.Printf("warning: failed to extend lock, %s", err)
+				continue
+			}
+
+			if res.MatchedCount == 0 {
+				l.m.log.Printf("warning: lock has been taken over by another process")
+				close(l.lost)
+				return
+			}
+
+			l.expires = now.Add(l.ttl)
+		}
+	}
+}
+
+/*
+Err returns ErrLockLost once the lease has been taken over or has expired, nil while it is held.
+
+ロックが他のプロセスに奪われた、または期限切れとなった場合は ErrLockLost を返します。
+*/
+func (l *Lease) Err() error {
+	select {
+	case <-l.lost:
+		return ErrLockLost
+	default:
+		return nil
+	}
+}
+
+/*
+Release stops heartbeat and removes the lease if it is still ours.
+
+ハートビートを停止し、自身が保持しているロックを解放します。
+*/
+func (l *Lease) Release(ctx context.Context) error {
+	close(l.stop)
+	l.wg.Wait()
+
+	c, cancel := l.m.withTimeout(ctx)
+	defer cancel()
+
+	q := bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: l.owner}}
+
+	if _, err := l.m.collection().DeleteOne(c, q); err != nil {
+		return fmt.Errorf("failed to release lock, %s", err)
+	}
+
+	return nil
+}
+
+// locked runs fn while holding the lock, after history written by older versions has been converted.
+func (m *Migrator) locked(ctx context.Context, fn func() error) error {
+	lease, err := m.AcquireLock(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Release even after ctx is done.
+		if err := lease.Release(context.WithoutCancel(ctx)); err != nil {
+			m.log.Printf("warning: %s", err)
+		}
+	}()
+
+	if err := m.upgradeHistory(ctx); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+/*
+CurrentLock returns the lease document, or nil when nobody holds it.
+
+現在のロックを返します。ロックが存在しない場合は nil を返します。
+*/
+func (m *Migrator) CurrentLock(ctx context.Context) (*Lock, error) {
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	var out Lock
+
+	q := bson.D{{Key: "_id", Value: lockID}}
+
+	err := m.collection().FindOne(c, q).Decode(&out)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup lock, %s", err)
+	}
+
+	return &out, nil
+}
+
+/*
+Unlock removes the lease. Unless force is given, only an expired lease is removed.
+
+ロックを削除します。force が指定されない場合は期限切れのロックのみ削除します。
+*/
+func (m *Migrator) Unlock(ctx context.Context, force bool) (*Lock, error) {
+	held, err := m.CurrentLock(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if held == nil {
+		return nil, nil
+	}
+
+	if !force && held.ExpiresAt.After(time.Now()) {
+		return held, fmt.Errorf("lock is held by %s until %s, use --force to remove it", held.Owner, held.ExpiresAt.Local().Format(time.RFC3339))
+	}
+
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	q := bson.D{{Key: "_id", Value: lockID}}
+
+	if _, err := m.collection().DeleteOne(c, q); err != nil {
+		return held, fmt.Errorf("failed to remove lock, %s", err)
+	}
+
+	return held, nil
+}
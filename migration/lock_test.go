@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAcquireLock(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator("demo", Options{LockTTL: time.Minute})
+
+	// Setup
+	func() {
+		if err := m.collection().Drop(ctx); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+	}()
+
+	lease, err := m.AcquireLock(ctx)
+
+	if err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	// Fails while another lease is held.
+	func() {
+		if _, err := m.AcquireLock(ctx); err == nil {
+			t.Errorf("should fail while locked")
+		}
+	}()
+
+	// Fails to unlock without force.
+	func() {
+		if _, err := m.Unlock(ctx, false); err == nil {
+			t.Errorf("should fail without force")
+		}
+	}()
+
+	// OK after release.
+	func() {
+		if err := lease.Release(ctx); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		again, err := m.AcquireLock(ctx)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if _, err := m.Unlock(ctx, true); err != nil {
+			t.Errorf("should not fail with force, error %s", err)
+		}
+
+		if err := again.Release(ctx); err != nil {
+			t.Errorf("should not fail, error %s", err)
+		}
+	}()
+
+	// Fails on invalid ttl.
+	func() {
+		invalid := newTestMigrator("demo", Options{LockTTL: -time.Second})
+
+		if _, err := invalid.AcquireLock(ctx); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
+
+func TestLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator("demo", Options{LockTTL: 300 * time.Millisecond})
+
+	// Setup
+	func() {
+		if err := m.collection().Drop(ctx); err != nil {
+			panic(fmt.Sprintf("should not fail, error %s", err))
+		}
+	}()
+
+	lease, err := m.AcquireLock(ctx)
+
+	if err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	defer lease.Release(ctx)
+
+	if err := lease.Err(); err != nil {
+		t.Errorf("should be held, error %s", err)
+	}
+
+	// Another process takes over the lease.
+	q := bson.D{{Key: "_id", Value: lockID}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: "other"}}}}
+
+	if _, err := m.collection().UpdateOne(ctx, q, update); err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	select {
+	case <-lease.lost:
+	case <-time.After(time.Second):
+	}
+
+	if !errors.Is(lease.Err(), ErrLockLost) {
+		t.Errorf("should be lost, error %s", lease.Err())
+	}
+}
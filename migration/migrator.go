@@ -0,0 +1,905 @@
+/*
+Package migration runs MongoDB migrations written in JSON files.
+
+JSON ファイルで記述された MongoDB のマイグレーションを実行します。
+*/
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Version is recorded to migration history along with each entry.
+const Version = "0.7.0"
+
+const migrationCollection = "migrations"
+const legacyMigrationKey = "latest"
+const migrationInitValue = "0"
+
+// defaultTimeout bounds each database operation.
+const defaultTimeout = 30 * time.Second
+
+// ErrInterrupted is returned when ctx is cancelled while running migrations.
+// The running step is completed, and the rest is left for the next run.
+var ErrInterrupted = errors.New("migration interrupted")
+
+// Logger receives progress messages, *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+
+// Options configures Migrator.
+type Options struct {
+	// Dir is the directory of JSON files, "migrations" by default.
+	// When FS is given, Dir is a path within FS.
+	Dir string
+	// FS is the source of JSON files such as embed.FS, the local disk is used when nil.
+	FS fs.FS
+	// ResourceGroup is used for admin commands run through az.
+	ResourceGroup string
+	// AccountName is Cosmos DB account name used for admin commands run through az.
+	AccountName string
+	// Subscription is passed to az when given, the default subscription of az is used otherwise.
+	Subscription string
+	// Executor runs admin commands of ProviderCosmosRU, AzCLI by default.
+	// Give *ARMClient to run them without az, *Recorder or *Printer to run nothing.
+	Executor Executor
+	// Provider decides how admin commands are executed, ProviderCosmosRU by default.
+	Provider Provider
+	// LockTTL is lifetime of the lock held by Up and Down, 2 minutes by default.
+	LockTTL time.Duration
+	// Timeout bounds each command of migration files, 30 seconds by default.
+	// timeout of a file takes precedence.
+	Timeout time.Duration
+	// AzTimeout bounds each az process, 10 minutes by default.
+	// timeout of a file takes precedence.
+	AzTimeout time.Duration
+	// Retry configures retry of requests throttled by Cosmos DB.
+	Retry Retry
+	// Interval is the wait between files on Up, 2 seconds by default, negative disables waiting.
+	Interval time.Duration
+	// Pacing decides how Interval applies, PacingFixed by default.
+	Pacing Pacing
+	// Logger receives progress messages, discarded by default.
+	Logger Logger
+	// OnEvent receives structured progress reports along with Logger when given.
+	OnEvent func(Event)
+}
+
+// Migrator runs migrations against a single database.
+type Migrator struct {
+	client *mongo.Client
+	db     *mongo.Database
+	opts   Options
+	log    Logger
+
+	// throttles counts throttled requests, so pacing can tell whether a file has been throttled.
+	throttles atomic.Int64
+
+	// fsys and root locate JSON files, root is "." when reading Dir from the local disk.
+	fsys fs.FS
+	root string
+}
+
+/*
+New returns Migrator for the given database.
+
+指定されたデータベースに対する Migrator を返します。
+*/
+func New(client *mongo.Client, database string, opts Options) *Migrator {
+	if opts.Dir == "" {
+		opts.Dir = "migrations"
+	}
+
+	if opts.LockTTL == 0 {
+		opts.LockTTL = 2 * time.Minute
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	if opts.AzTimeout == 0 {
+		opts.AzTimeout = 10 * time.Minute
+	}
+
+	opts.Retry = opts.Retry.withDefaults()
+
+	if opts.Interval == 0 {
+		opts.Interval = 2 * time.Second
+	}
+
+	if opts.Pacing == "" {
+		opts.Pacing = PacingFixed
+	}
+
+	if opts.Provider == "" {
+		opts.Provider = ProviderCosmosRU
+	}
+
+	if opts.Executor == nil {
+		opts.Executor = AzCLI{}
+	}
+
+	var log Logger = discard{}
+
+	if opts.Logger != nil {
+		log = opts.Logger
+	}
+
+	fsys, root := opts.FS, opts.Dir
+
+	if fsys == nil {
+		fsys, root = os.DirFS(opts.Dir), "."
+	}
+
+	return &Migrator{
+		client: client,
+		db:     client.Database(database),
+		opts:   opts,
+		log:    log,
+		fsys:   fsys,
+		root:   root,
+	}
+}
+
+// withTimeout bounds a single database operation.
+func (m *Migrator) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, defaultTimeout)
+}
+
+func (m *Migrator) collection() *mongo.Collection {
+	return m.db.Collection(migrationCollection)
+}
+
+/*
+Init sets up collection to store migration history.
+Admin steps of the first file creating the database run beforehand on Cosmos DB (RU),
+since writing the history creates the database without shared throughput.
+
+マイグレーション履歴を保存するためのコレクションをセットアップします。
+*/
+func (m *Migrator) Init(ctx context.Context) error {
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to lookup migration history, %s", err)
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("record already exists for migration history")
+	}
+
+	if err := m.provision(ctx); err != nil {
+		return fmt.Errorf("failed to provision database, %s", err)
+	}
+
+	if err := m.record(ctx, newEntry(migrationInitValue, StatusInit)); err != nil {
+		return fmt.Errorf("failed to setup initial collection, %s", err)
+	}
+
+	return nil
+}
+
+// provision runs admin steps creating the database in the first file.
+// Up runs them again, which are skipped as the database exists with the same settings.
+func (m *Migrator) provision(ctx context.Context) error {
+	if _, ok := m.adminRunner().(azAdmin); !ok {
+		return nil
+	}
+
+	paths, err := m.listFiles()
+
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	in, err := m.parse(paths[0])
+
+	if err != nil {
+		return err
+	}
+
+	for _, s := range in.Steps() {
+		if s.Kind != StepAdmin {
+			continue
+		}
+
+		var cmd AzureCommand
+
+		if err := json.Unmarshal([]byte(s.Raw), &cmd); err != nil {
+			return err
+		}
+
+		action, err := cmd.resolve(Create)
+
+		if err != nil {
+			return err
+		}
+
+		if cmd.Scope != ScopeDatabase || action != Create {
+			continue
+		}
+
+		if _, err := m.runStep(ctx, in, s, Create); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func filename(given string) string {
+	chunks := strings.Split(given, "/")
+
+	return chunks[len(chunks)-1]
+}
+
+/*
+Current retrieves the latest version from migration history.
+
+マイグレーション履歴から最新のバージョンを取得します。
+*/
+func (m *Migrator) Current(ctx context.Context) (string, error) {
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	cur, ok := latest(entries)
+
+	if !ok {
+		return "", fmt.Errorf("failed to retrieve migration history")
+	}
+
+	return cur, nil
+}
+
+// listFiles returns migration files within the directory in applying order.
+func (m *Migrator) listFiles() ([]string, error) {
+	paths, err := fs.Glob(m.fsys, path.Join(m.root, "*.json"))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob, %s", err)
+	}
+
+	return paths, nil
+}
+
+func (m *Migrator) parse(name string) (*Command, error) {
+	cmd, err := parseCommand(m.fsys, name, m.db.Name())
+
+	if err != nil || cmd == nil {
+		return nil, fmt.Errorf("failed to parse JSON, schema is possibly broken, %s", err)
+	}
+
+	return cmd, nil
+}
+
+/*
+Next returns a migration target within the directory.
+
+ディレクトリ内のマイグレーション対象を返します。
+*/
+func (m *Migrator) Next(current string) (*Command, error) {
+
+	paths, err := m.listFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("directory does not contain any schema files in JSON")
+	}
+
+	// Return the first match when called after init.
+	if current == migrationInitValue {
+		return m.parse(paths[0])
+	}
+
+	idx := indexOf(paths, current)
+
+	// Pointer must not be lost, otherwise the rest would silently be skipped.
+	if idx < 0 {
+		return nil, fmt.Errorf("current version %s does not exist in the directory", current)
+	}
+
+	// Current item is the last element.
+	if len(paths) == idx+1 {
+		return nil, nil
+	}
+
+	// Matched to current item, attempt to get next one.
+	return m.parse(paths[idx+1])
+}
+
+/*
+CheckTarget validates the target version given to Up.
+
+Up に指定されたバージョンを検証します。
+*/
+func (m *Migrator) CheckTarget(current, to string) error {
+	paths, err := m.listFiles()
+
+	if err != nil {
+		return err
+	}
+
+	target := indexOf(paths, to)
+
+	if target < 0 {
+		return fmt.Errorf("target version %s does not exist in the directory", to)
+	}
+
+	if current == migrationInitValue {
+		return nil
+	}
+
+	if cur := indexOf(paths, current); cur >= 0 && target < cur {
+		return fmt.Errorf("target version %s is behind current version %s", to, current)
+	}
+
+	return nil
+}
+
+// UpOptions limits what Up applies.
+type UpOptions struct {
+	// To stops after the given file has been applied.
+	To string
+	// Steps stops after the given number of files have been applied.
+	Steps int
+	// AllowOutOfOrder applies files sorting before current version which have never been applied.
+	AllowOutOfOrder bool
+}
+
+func (o UpOptions) validate() error {
+	if o.To != "" && o.Steps != 0 {
+		return fmt.Errorf("steps and to cannot be specified at the same time")
+	}
+
+	if o.Steps < 0 {
+		return fmt.Errorf("steps must be positive")
+	}
+
+	return nil
+}
+
+/*
+Up applies pending files while holding the lock.
+It stops with ErrLockLost before the next file once the lock has been lost.
+
+ロックを保持した状態で、未適用のファイルを適用します。
+*/
+func (m *Migrator) Up(ctx context.Context, opts UpOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	if err := m.opts.Pacing.validate(); err != nil {
+		return err
+	}
+
+	if err := m.opts.Provider.validate(); err != nil {
+		return err
+	}
+
+	lease, err := m.AcquireLock(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Release even after ctx is done.
+		if err := lease.Release(context.WithoutCancel(ctx)); err != nil {
+			m.log.Printf("warning: %s", err)
+		}
+	}()
+
+	if err := m.upgradeHistory(ctx); err != nil {
+		return err
+	}
+
+	drifts, err := m.Verify(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(drifts) > 0 {
+		for _, d := range drifts {
+			m.log.Printf("%s has changed since applied", d.Version)
+			m.emit(Event{Phase: PhaseModified, Version: d.Version})
+		}
+
+		return fmt.Errorf("checksum mismatch on applied files, run `migrate repair` to accept the changes")
+	}
+
+	skipped, err := m.Skipped(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(skipped) > 0 && !opts.AllowOutOfOrder {
+		for _, s := range skipped {
+			m.log.Printf("%s sorts before current version but has never been applied", s.Version)
+		}
+
+		return fmt.Errorf("found %d skipped file(s), use --allow-out-of-order to apply them", len(skipped))
+	}
+
+	for _, s := range skipped {
+		if ctx.Err() != nil {
+			return m.interruptBefore(ctx, s)
+		}
+
+		if err := lease.Err(); err != nil {
+			return err
+		}
+
+		m.log.Printf("applying %s out of order", s.Version)
+
+		throttles := m.throttles.Load()
+
+		if err := m.ApplyOutOfOrder(ctx, s); err != nil {
+			return err
+		}
+
+		m.log.Printf("completed migration %s", s.Version)
+		m.wait(ctx, m.pause(s, m.throttles.Load() > throttles))
+	}
+
+	if opts.To != "" {
+		cur, err := m.Current(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if err := m.CheckTarget(cur, opts.To); err != nil {
+			return err
+		}
+	}
+
+	applied := 0
+
+	for {
+		if ctx.Err() != nil {
+			m.log.Printf("interrupted, applied %d file(s)", applied)
+			return m.interruptPending(ctx)
+		}
+
+		// Another process may be running migrations once the lease is lost.
+		if err := lease.Err(); err != nil {
+			m.log.Printf("stopped, applied %d file(s)", applied)
+			return err
+		}
+
+		if opts.Steps > 0 && applied >= opts.Steps {
+			m.log.Printf("applied %d file(s)", applied)
+			break
+		}
+
+		cur, err := m.Current(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		m.emit(Event{Phase: PhaseCurrent, Version: cur})
+
+		if opts.To != "" && cur == opts.To {
+			m.log.Printf("reached %s", opts.To)
+			break
+		}
+
+		next, err := m.Next(cur)
+
+		if err != nil {
+			return err
+		}
+
+		if next == nil {
+			m.emit(Event{Phase: PhaseNext})
+			m.log.Printf("no more migrations")
+			break
+		}
+
+		m.emit(Event{Phase: PhaseNext, Version: next.Version})
+
+		m.log.Printf("applying %s", next.Version)
+
+		throttles := m.throttles.Load()
+
+		if err := m.Apply(ctx, next); err != nil {
+			return err
+		}
+
+		m.log.Printf("completed migration %s", next.Version)
+		applied++
+
+		// Wait to reduce database load.
+		m.wait(ctx, m.pause(next, m.throttles.Load() > throttles))
+	}
+
+	return nil
+}
+
+// interruptPending records the interruption against the next pending file, when there is one.
+func (m *Migrator) interruptPending(ctx context.Context) error {
+	rctx := context.WithoutCancel(ctx)
+
+	cur, err := m.Current(rctx)
+
+	if err != nil {
+		m.log.Printf("warning: failed to record interruption, %s", err)
+		return ErrInterrupted
+	}
+
+	next, err := m.Next(cur)
+
+	if err != nil || next == nil {
+		return ErrInterrupted
+	}
+
+	return m.interruptBefore(ctx, next)
+}
+
+// interruptBefore records the file which has not started because of cancellation.
+// Steps completed by the last attempt are kept, so the file resumes from there.
+func (m *Migrator) interruptBefore(ctx context.Context, in *Command) error {
+	rctx := context.WithoutCancel(ctx)
+
+	entries, err := m.History(rctx)
+
+	if err != nil {
+		m.log.Printf("warning: failed to record interruption, %s", err)
+		return ErrInterrupted
+	}
+
+	done := resumePoint(entries, in)
+
+	m.log.Printf("interrupted before %s", in.Version)
+	m.emit(Event{Phase: PhaseInterrupted, Version: in.Version, Step: done + 1, Error: ErrInterrupted.Error()})
+
+	e := newEntry(in.Version, StatusInterrupted)
+	e.Checksum = in.Checksum
+	e.CompletedSteps = done
+	e.Error = ErrInterrupted.Error()
+
+	if err := m.record(rctx, e); err != nil {
+		m.log.Printf("warning: failed to record interruption, %s", err)
+	}
+
+	return ErrInterrupted
+}
+
+/*
+Apply changes to target database, and record the outcome to migration history.
+
+データベースに変更を適用し、結果をマイグレーション履歴に記録します。
+
+	@param in *Command
+*/
+func (m *Migrator) Apply(ctx context.Context, in *Command) error {
+	return m.apply(ctx, in, "")
+}
+
+/*
+ApplyOutOfOrder applies a file sorting before current version, and keeps the pointer at current.
+
+現在のバージョンより前の未適用ファイルを適用します。マイグレーションポインタは移動しません。
+*/
+func (m *Migrator) ApplyOutOfOrder(ctx context.Context, in *Command) error {
+	cur, err := m.Current(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return m.apply(ctx, in, cur)
+}
+
+// apply runs the file and records outcome, pointer is kept at the given version when not empty.
+func (m *Migrator) apply(ctx context.Context, in *Command, pointer string) error {
+	if in == nil {
+		return fmt.Errorf("invalid command given")
+	}
+
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// Skip steps completed by the last failed attempt.
+	from := resumePoint(entries, in)
+
+	if from > 0 {
+		m.log.Printf("resuming %s from step %d", in.Version, from+1)
+	}
+
+	started := time.Now()
+
+	// Outcome must be recorded even after ctx is cancelled.
+	rctx := context.WithoutCancel(ctx)
+
+	done, responses, err := m.execute(ctx, in, from)
+
+	if err != nil {
+		status, phase := StatusFailed, PhaseFailed
+
+		if errors.Is(err, ErrInterrupted) {
+			status, phase = StatusInterrupted, PhaseInterrupted
+			m.log.Printf("interrupted %s after step %d", in.Version, done)
+		}
+
+		m.emit(Event{Phase: phase, Version: in.Version, Step: done + 1, DurationMs: time.Since(started).Milliseconds(), Error: err.Error()})
+
+		e := newEntry(in.Version, status)
+		e.DurationMs = time.Since(started).Milliseconds()
+		e.Checksum = in.Checksum
+		e.CompletedSteps = done
+		e.Responses = responses
+		e.Error = err.Error()
+
+		if rerr := m.record(rctx, e); rerr != nil {
+			return fmt.Errorf("%w, %s", err, rerr)
+		}
+
+		return err
+	}
+
+	// After everything is done, record the version as applied.
+	e := newEntry(in.Version, StatusApplied)
+	e.DurationMs = time.Since(started).Milliseconds()
+	e.Checksum = in.Checksum
+	e.Pointer = pointer
+	e.Responses = responses
+
+	m.emit(Event{Phase: PhaseCompleted, Version: in.Version, DurationMs: e.DurationMs})
+
+	return m.record(rctx, e)
+}
+
+// execute runs steps of the given file starting from the given position,
+// and returns number of steps completed in total along with replies of the steps run.
+// Cancellation of ctx stops before the next step, the running step is never cut off halfway.
+func (m *Migrator) execute(ctx context.Context, in *Command, from int) (int, []Response, error) {
+	steps := in.Steps()
+
+	var responses []Response
+
+	for i := from; i < len(steps); i++ {
+		if ctx.Err() != nil {
+			return i, responses, ErrInterrupted
+		}
+
+		if steps[i].Description != "" {
+			m.log.Printf("%s", steps[i].Description)
+		}
+
+		started := time.Now()
+
+		reply, err := m.runStep(context.WithoutCancel(ctx), in, steps[i], Create)
+
+		ev := Event{Phase: Phase(steps[i].Kind), Version: in.Version, Step: i + 1, DurationMs: time.Since(started).Milliseconds()}
+
+		if reply != nil {
+			r := Response{Step: i, Summary: summarize(reply), Reply: trimReply(reply)}
+			responses = append(responses, r)
+			ev.Summary = r.Summary
+			m.log.Printf("step %d: %s", i+1, r.Summary)
+		}
+
+		if err != nil {
+			ev.Error = err.Error()
+		}
+
+		m.emit(ev)
+
+		if err != nil {
+			return i, responses, err
+		}
+	}
+
+	return len(steps), responses, nil
+}
+
+// runStep runs a step of the given file within its timeout, action applies to admin step.
+// Reply is returned when the step ran as a database command, even on error embedded in the reply.
+func (m *Migrator) runStep(ctx context.Context, in *Command, s Step, action Action) (bson.M, error) {
+	timeout := m.stepTimeout(in, s.Kind)
+
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if s.Kind != StepAdmin && s.Kind != StepCommand {
+		return nil, fmt.Errorf("invalid step %s", s.Kind)
+	}
+
+	var reply bson.M
+
+	err := m.withRetry(c, in.Version, func() error {
+		var err error
+
+		if s.Kind == StepAdmin {
+			reply, err = m.adminRunner().run(c, s.Raw, action)
+			return err
+		}
+
+		reply, err = m.runCommand(c, m.db, s.Raw)
+		return err
+	})
+
+	if err != nil && errors.Is(c.Err(), context.DeadlineExceeded) {
+		return reply, fmt.Errorf("timed out after %s, raise timeout of the file if it needs longer, %s", timeout, err)
+	}
+
+	return reply, err
+}
+
+// stepTimeout returns timeout of the given kind of step in the file.
+func (m *Migrator) stepTimeout(in *Command, kind string) time.Duration {
+	if in.Timeout > 0 {
+		return in.Timeout
+	}
+
+	if kind == StepAdmin && m.adminRunner().external() {
+		return m.opts.AzTimeout
+	}
+
+	return m.opts.Timeout
+}
+
+// azArgs builds arguments of az for the admin command with settings of Migrator.
+func (m *Migrator) azArgs(cmd AzureCommand, action Action) ([]string, error) {
+	args, err := cmd.CreateCommand(action, m.opts.ResourceGroup, m.opts.AccountName, m.db.Name())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if m.opts.Subscription != "" {
+		args = append(args, "--subscription", m.opts.Subscription)
+	}
+
+	// az asks for confirmation on delete, which cannot be answered from here.
+	if action == Delete {
+		args = append(args, "--yes")
+	}
+
+	return args, nil
+}
+
+// runCommand runs the given Extended JSON as a command against the database,
+// and fails on errors embedded in the reply such as writeErrors.
+func (m *Migrator) runCommand(ctx context.Context, target *mongo.Database, raw string) (bson.M, error) {
+	var cmd bson.D
+
+	if err := bson.UnmarshalExtJSON([]byte(raw), true, &cmd); err != nil {
+		return nil, err
+	}
+
+	opts := options.RunCmd().SetReadPreference(readpref.Primary())
+
+	var out bson.M
+
+	if err := target.RunCommand(ctx, cmd, opts).Decode(&out); err != nil {
+		return nil, err
+	}
+
+	return out, checkReply(out)
+}
+
+/*
+Fix runs the given file within the directory again without moving the pointer.
+Admin steps are skipped unless admin is true.
+
+ディレクトリ内のマイグレーションに失敗したファイルを、ポインタを移動せずに再実行します。
+admin が false の場合は adminCommand を実行しません。
+*/
+func (m *Migrator) Fix(ctx context.Context, file string, admin bool) error {
+	in, err := m.parse(path.Join(m.root, file))
+	if err != nil {
+		return err
+	}
+
+	for _, step := range in.Steps() {
+		// Run admin command only when the flag is given.
+		if step.Kind == StepAdmin && !admin {
+			continue
+		}
+
+		if step.Description != "" {
+			m.log.Printf("%s", step.Description)
+		}
+
+		if step.Kind == StepCommand {
+			m.log.Printf("%s", step.Raw)
+		}
+
+		reply, err := m.runStep(ctx, in, step, Create)
+
+		if reply != nil {
+			m.log.Printf("%s", summarize(reply))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Revert moves migration pointer to the given file name while holding the lock.
+
+マイグレーションポインタを指定されたファイル名に移動します。
+*/
+func (m *Migrator) Revert(ctx context.Context, fileName string) error {
+	return m.locked(ctx, func() error {
+		return m.record(ctx, newEntry(fileName, StatusReverted))
+	})
+}
+
+/*
+Indexes returns indexes of the given collection.
+
+指定されたコレクションのインデックスを返します。
+*/
+func (m *Migrator) Indexes(ctx context.Context, collectionName string) ([]bson.M, error) {
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	var result []bson.M
+
+	err := m.withRetry(c, collectionName, func() error {
+		indexView := m.db.Collection(collectionName).Indexes()
+		opts := options.ListIndexes().SetMaxTime(2 * time.Second)
+		cursor, err := indexView.List(c, opts)
+		if err != nil {
+			return err
+		}
+		return cursor.All(c, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteIndex は指定されたコレクションのインデックスを削除します。
+// https://www.mongodb.com/ja-jp/docs/drivers/go/current/fundamentals/indexes/#remove-an-index
+func (m *Migrator) DeleteIndex(ctx context.Context, collectionName, indexName string) error {
+	c, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	coll := m.db.Collection(collectionName)
+	return m.withRetry(c, collectionName, func() error {
+		_, err := coll.Indexes().DropOne(c, indexName)
+		return err
+	})
+}
@@ -0,0 +1,88 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+)
+
+// isApplied reports whether the version is applied according to appliedVersions.
+func isApplied(applied map[string]bool, floor, version string) bool {
+	if applied[version] {
+		return true
+	}
+
+	return floor != migrationInitValue && version <= floor
+}
+
+// skipped returns files sorting before current version which have never been applied.
+func skipped(paths []string, cur string, entries []Entry) ([]string, error) {
+	if cur == migrationInitValue {
+		return nil, nil
+	}
+
+	idx := indexOf(paths, cur)
+
+	if idx < 0 {
+		return nil, fmt.Errorf("current version %s does not exist in the directory", cur)
+	}
+
+	applied, floor := appliedVersions(entries)
+
+	var out []string
+
+	for _, p := range paths[:idx] {
+		if v := filename(p); !isApplied(applied, floor, v) {
+			out = append(out, p)
+		}
+	}
+
+	return out, nil
+}
+
+/*
+Skipped returns files sorting before current version which have never been applied,
+e.g. merged from a long-lived branch with a lower number.
+It fails when current version no longer exists in the directory.
+
+現在のバージョンより前にあるにもかかわらず、一度も適用されていないファイルを返します。
+現在のバージョンがディレクトリに存在しない場合はエラーを返します。
+*/
+func (m *Migrator) Skipped(ctx context.Context) ([]*Command, error) {
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cur, ok := latest(entries)
+
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve migration history")
+	}
+
+	paths, err := m.listFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := skipped(paths, cur, entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Command
+
+	for _, p := range found {
+		cmd, err := m.parse(p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, cmd)
+	}
+
+	return out, nil
+}
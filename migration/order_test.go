@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppliedVersions(t *testing.T) {
+	now := time.Now()
+
+	entries := []Entry{
+		{Version: "0", Status: StatusInit, AppliedAt: now},
+		{Version: "000000002_admins.json", Status: StatusBaseline, AppliedAt: now},
+		{Version: "000000003-compound.json", Status: StatusApplied, AppliedAt: now},
+		{Version: "000000004-admin-only.json", Status: StatusFailed, AppliedAt: now},
+	}
+
+	applied, floor := appliedVersions(entries)
+
+	type pattern struct {
+		version string
+		exp     bool
+	}
+
+	pats := []pattern{
+		{"000000001_users.json", true},
+		{"000000002_admins.json", true},
+		{"000000003-compound.json", true},
+		{"000000004-admin-only.json", false},
+	}
+
+	for idx, p := range pats {
+		if got := isApplied(applied, floor, p.version); got != p.exp {
+			t.Errorf("case %d expected %v, got %v", idx, p.exp, got)
+		}
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	paths, err := newTestMigrator("demo", Options{Dir: "../examples"}).listFiles()
+
+	if err != nil {
+		t.Errorf("should not fail, error %s", err)
+		return
+	}
+
+	// Detects file which has never been applied.
+	func() {
+		entries := []Entry{
+			{Version: "0", Status: StatusInit},
+			{Version: "000000001_users.json", Status: StatusApplied},
+			{Version: "000000003-compound.json", Status: StatusApplied},
+		}
+
+		got, err := skipped(paths, "000000003-compound.json", entries)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(got) != 1 || filename(got[0]) != "000000002_admins.json" {
+			t.Errorf("should detect skipped file, got %v", got)
+		}
+	}()
+
+	// OK when pointer has been moved by revert.
+	func() {
+		entries := []Entry{
+			{Version: "0", Status: StatusInit},
+			{Version: "000000003-compound.json", Status: StatusReverted},
+		}
+
+		got, err := skipped(paths, "000000003-compound.json", entries)
+
+		if err != nil || len(got) != 0 {
+			t.Errorf("should be empty, got %v, error %s", got, err)
+		}
+	}()
+
+	// Fails when current does not exist.
+	func() {
+		if _, err := skipped(paths, "000000009_unknown.json", nil); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
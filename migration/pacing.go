@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Pacing decides the wait between files on Up to reduce database load.
+type Pacing string
+
+const (
+	// PacingFixed waits Interval after every file.
+	PacingFixed Pacing = "fixed"
+	// PacingAdaptive waits only after files which load the database,
+	// 2x Interval after index builds and admin commands, 4x Interval after throttling.
+	PacingAdaptive Pacing = "adaptive"
+)
+
+// heavyCommands keep the server busy after returning, e.g. index build continues in background.
+var heavyCommands = map[string]bool{
+	"createIndexes":   true,
+	"reIndex":         true,
+	"shardCollection": true,
+}
+
+func (p Pacing) validate() error {
+	switch p {
+	case PacingFixed, PacingAdaptive:
+		return nil
+	default:
+		return fmt.Errorf("pacing must be either %s or %s", PacingFixed, PacingAdaptive)
+	}
+}
+
+// heavy reports whether the file builds indexes or runs admin commands.
+func (c *Command) heavy() bool {
+	for _, s := range c.Steps() {
+		if s.Kind == StepAdmin {
+			return true
+		}
+
+		var cmd bson.D
+
+		if err := bson.UnmarshalExtJSON([]byte(s.Raw), true, &cmd); err == nil && len(cmd) > 0 && heavyCommands[cmd[0].Key] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pause returns the wait after the given file, throttled tells whether any request of the file has been throttled.
+func (m *Migrator) pause(in *Command, throttled bool) time.Duration {
+	if m.opts.Interval < 0 {
+		return 0
+	}
+
+	if m.opts.Pacing != PacingAdaptive {
+		return m.opts.Interval
+	}
+
+	switch {
+	case throttled:
+		return 4 * m.opts.Interval
+	case in.heavy():
+		return 2 * m.opts.Interval
+	default:
+		return 0
+	}
+}
+
+// wait sleeps for the given duration unless ctx is done.
+func (m *Migrator) wait(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	m.log.Printf("waiting %s", d)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
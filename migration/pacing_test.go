@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	fixed := newTestMigrator("demo", Options{Interval: time.Second})
+	adaptive := newTestMigrator("demo", Options{Interval: time.Second, Pacing: PacingAdaptive})
+	disabled := newTestMigrator("demo", Options{Interval: -1, Pacing: PacingAdaptive})
+
+	light := &Command{General: `{"insert": "users", "documents": [{"a": 1}]}`}
+	index := &Command{General: `{"createIndexes": "users", "indexes": [{"key": {"a": 1}, "name": "a"}]}`}
+	admin := &Command{Admin: `{"collection": "users", "shardKey": "_id"}`}
+
+	type pattern struct {
+		m         *Migrator
+		in        *Command
+		throttled bool
+		exp       time.Duration
+	}
+
+	pats := []pattern{
+		{fixed, light, false, time.Second},
+		{fixed, index, true, time.Second},
+		{adaptive, light, false, 0},
+		{adaptive, &Command{}, false, 0},
+		{adaptive, index, false, 2 * time.Second},
+		{adaptive, admin, false, 2 * time.Second},
+		{adaptive, light, true, 4 * time.Second},
+		{disabled, index, true, 0},
+	}
+
+	for idx, p := range pats {
+		if got := p.m.pause(p.in, p.throttled); got != p.exp {
+			t.Errorf("case %d expected %s, got %s", idx, p.exp, got)
+		}
+	}
+}
+
+func TestPacingValidate(t *testing.T) {
+	if err := newTestMigrator("demo", Options{}).opts.Pacing.validate(); err != nil {
+		t.Errorf("should not fail by default, error %s", err)
+	}
+
+	if err := Pacing("random").validate(); err == nil {
+		t.Errorf("should fail")
+	}
+}
@@ -0,0 +1,158 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PlannedStep represents what would be executed for a part of a file.
+type PlannedStep struct {
+	// Kind is either StepAdmin or StepCommand.
+	Kind string
+	// Az holds arguments given to az, when the step runs through Azure CLI.
+	Az []string
+	// Command holds Extended JSON, when the step runs against database.
+	Command     string
+	Description string
+}
+
+func (p PlannedStep) String() string {
+	out := fmt.Sprintf("%s: %s", p.Kind, p.Command)
+
+	if p.Az != nil {
+		out = fmt.Sprintf("%s: az %s", p.Kind, strings.Join(p.Az, " "))
+	}
+
+	if p.Description != "" {
+		out = fmt.Sprintf("%s (%s)", out, p.Description)
+	}
+
+	return out
+}
+
+/*
+PlanCommand resolves what execute would run for the given file, without running it.
+
+ファイルの実行内容を、実行せずに解決して返します。
+*/
+func (m *Migrator) PlanCommand(in *Command, admin bool) ([]PlannedStep, error) {
+	if in == nil {
+		return nil, fmt.Errorf("invalid command given")
+	}
+
+	var out []PlannedStep
+
+	for _, step := range in.Steps() {
+		switch step.Kind {
+		case StepAdmin:
+			if !admin {
+				continue
+			}
+
+			planned, err := m.adminRunner().plan(step.Raw, Create)
+
+			if err != nil {
+				return nil, err
+			}
+
+			planned.Description = step.Description
+			out = append(out, planned)
+		case StepCommand:
+			ext, err := normalizeExtJSON(step.Raw)
+
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, PlannedStep{Kind: StepCommand, Command: ext, Description: step.Description})
+		}
+	}
+
+	return out, nil
+}
+
+// normalizeExtJSON parses the command the same way as runCommand, and returns it as relaxed Extended JSON.
+func normalizeExtJSON(raw string) (string, error) {
+	var cmd bson.D
+
+	if err := bson.UnmarshalExtJSON([]byte(raw), true, &cmd); err != nil {
+		return "", err
+	}
+
+	out, err := bson.MarshalExtJSON(cmd, false, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+/*
+PlanUp returns files `up` would apply from current version, without changing the pointer.
+To or Steps of opts limits the range, same as `up`.
+
+現在のバージョンから `up` が適用するファイルを、ポインタを変更せずに返します。
+*/
+func (m *Migrator) PlanUp(ctx context.Context, opts UpOptions) ([]*Command, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	cur, err := m.Current(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.To != "" {
+		if err := m.CheckTarget(cur, opts.To); err != nil {
+			return nil, err
+		}
+	}
+
+	var out []*Command
+
+	for {
+		if opts.Steps > 0 && len(out) >= opts.Steps {
+			break
+		}
+
+		if opts.To != "" && cur == opts.To {
+			break
+		}
+
+		next, err := m.Next(cur)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if next == nil {
+			break
+		}
+
+		out = append(out, next)
+		cur = next.Version
+	}
+
+	return out, nil
+}
+
+/*
+PlanFix resolves what `fix` would run for the given file within the directory, without running it.
+
+`fix` が実行する内容を、実行せずに解決して返します。
+*/
+func (m *Migrator) PlanFix(file string, admin bool) ([]PlannedStep, error) {
+	in, err := m.parse(path.Join(m.root, file))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.PlanCommand(in, admin)
+}
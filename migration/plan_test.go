@@ -0,0 +1,113 @@
+package migration
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPlanCommand(t *testing.T) {
+	local := newTestMigrator("demo", Options{Provider: ProviderLocal, AccountName: "root", ResourceGroup: "develop"})
+	azure := newTestMigrator("demo", Options{AccountName: "account", ResourceGroup: "develop"})
+
+	// OK on local environment.
+	func() {
+		in, err := parseCommand(os.DirFS(".."), "examples/000000001_users.json", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		got, err := local.PlanCommand(in, true)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if len(got) != 2 || got[0].Kind != "admin" || got[1].Kind != "command" {
+			t.Errorf("should contain admin and command, got %#v", got)
+			return
+		}
+
+		if !strings.Contains(got[0].Command, `"shardCollection":"demo.users"`) {
+			t.Errorf("should substitute database name, got %s", got[0].Command)
+		}
+	}()
+
+	// OK on Azure.
+	func() {
+		in, err := parseCommand(os.DirFS(".."), "examples-v2/000000001_users.json", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		got, err := azure.PlanCommand(in, true)
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		exp := "admin: az cosmosdb mongodb collection create -g develop -a account -d demo -n users --shard _id --max-throughput 4000"
+
+		if len(got) != 2 || got[0].String() != exp {
+			t.Errorf("should be identical, got %#v", got)
+		}
+	}()
+
+	// OK with subscription.
+	func() {
+		in, _ := parseCommand(os.DirFS(".."), "examples-v2/000000001_users.json", "demo")
+
+		sub := newTestMigrator("demo", Options{AccountName: "account", ResourceGroup: "develop", Subscription: "staging"})
+
+		got, err := sub.PlanCommand(in, true)
+
+		if err != nil || len(got) != 2 || !strings.HasSuffix(got[0].String(), "--subscription staging") {
+			t.Errorf("should pass subscription to az, got %#v, error %s", got, err)
+		}
+	}()
+
+	// OK with steps.
+	func() {
+		in, err := parseCommand(os.DirFS(".."), "examples-v2/000000003_steps.json", "demo")
+
+		if err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		got, err := azure.PlanCommand(in, true)
+
+		if err != nil || len(got) != 4 {
+			t.Errorf("should contain every step, got %#v, error %s", got, err)
+			return
+		}
+
+		if got[2].Az == nil || got[2].Description == "" || got[3].Kind != StepCommand {
+			t.Errorf("should keep order and description, got %#v", got)
+		}
+	}()
+
+	// OK without admin.
+	func() {
+		in, _ := parseCommand(os.DirFS(".."), "examples/000000001_users.json", "demo")
+
+		got, err := local.PlanCommand(in, false)
+
+		if err != nil || len(got) != 1 || got[0].Kind != "command" {
+			t.Errorf("should contain command only, got %#v, error %s", got, err)
+		}
+	}()
+
+	// Fails on nil input.
+	func() {
+		if _, err := local.PlanCommand(nil, true); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
@@ -0,0 +1,330 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Provider names the kind of database, which decides how admin steps are executed.
+type Provider string
+
+const (
+	// ProviderLocal runs admin commands natively against admin database, e.g. MongoDB on docker-compose.
+	ProviderLocal Provider = "local"
+	// ProviderCosmosRU runs admin commands through az, for Azure Cosmos DB for MongoDB (RU).
+	ProviderCosmosRU Provider = "cosmos-ru"
+	// ProviderCosmosVCore runs admin commands natively, and also accepts admin commands written for az,
+	// so files for Cosmos DB (RU) can be applied to Azure Cosmos DB for MongoDB (vCore).
+	ProviderCosmosVCore Provider = "cosmos-vcore"
+	// ProviderSharded runs admin commands natively, enabling sharding of the database before shardCollection.
+	ProviderSharded Provider = "sharded"
+)
+
+// Providers lists every supported provider.
+var Providers = []Provider{ProviderLocal, ProviderCosmosRU, ProviderCosmosVCore, ProviderSharded}
+
+// alreadyInitialized is returned by enableSharding before MongoDB 4.4 when sharding is already enabled.
+const alreadyInitialized = 23
+
+func (p Provider) validate() error {
+	for _, v := range Providers {
+		if p == v {
+			return nil
+		}
+	}
+
+	names := make([]string, len(Providers))
+
+	for i, v := range Providers {
+		names[i] = string(v)
+	}
+
+	return fmt.Errorf("provider must be one of %s, got %s", strings.Join(names, ", "), p)
+}
+
+// adminRunner executes admin steps in the way the provider supports.
+type adminRunner interface {
+	// run executes the admin command, reply is nil when it does not come from the database.
+	run(ctx context.Context, raw string, action Action) (bson.M, error)
+	// plan resolves the admin command the same way as run, without running it.
+	plan(raw string, action Action) (PlannedStep, error)
+	// external reports admin commands run outside of the database, bounded by AzTimeout.
+	external() bool
+}
+
+// adminRunner returns the runner of the provider given to Options.
+func (m *Migrator) adminRunner() adminRunner {
+	switch m.opts.Provider {
+	case ProviderLocal:
+		return nativeAdmin{m}
+	case ProviderCosmosVCore:
+		return vcoreAdmin{nativeAdmin{m}}
+	case ProviderSharded:
+		return shardedAdmin{nativeAdmin{m}}
+	case ProviderCosmosRU:
+		return azAdmin{m}
+	default:
+		return unknownAdmin{m.opts.Provider}
+	}
+}
+
+// nativeAdmin runs admin commands against admin database.
+type nativeAdmin struct {
+	m *Migrator
+}
+
+func (a nativeAdmin) run(ctx context.Context, raw string, action Action) (bson.M, error) {
+	return a.m.runCommand(ctx, a.m.client.Database("admin"), raw)
+}
+
+func (a nativeAdmin) plan(raw string, action Action) (PlannedStep, error) {
+	ext, err := normalizeExtJSON(raw)
+
+	if err != nil {
+		return PlannedStep{}, err
+	}
+
+	return PlannedStep{Kind: StepAdmin, Command: ext}, nil
+}
+
+func (nativeAdmin) external() bool {
+	return false
+}
+
+// shardedAdmin enables sharding of the database before shardCollection, which MongoDB before 6.0 requires.
+type shardedAdmin struct {
+	nativeAdmin
+}
+
+func (a shardedAdmin) run(ctx context.Context, raw string, action Action) (bson.M, error) {
+	if db, ok := shardedDatabase(raw); ok {
+		admin := a.m.client.Database("admin")
+
+		if _, err := a.m.runCommand(ctx, admin, enableSharding(db)); err != nil && !isAlreadyInitialized(err) {
+			return nil, fmt.Errorf("failed to enable sharding of %s, %s", db, err)
+		}
+	}
+
+	return a.nativeAdmin.run(ctx, raw, action)
+}
+
+func (a shardedAdmin) plan(raw string, action Action) (PlannedStep, error) {
+	planned, err := a.nativeAdmin.plan(raw, action)
+
+	if err != nil {
+		return PlannedStep{}, err
+	}
+
+	if db, ok := shardedDatabase(raw); ok {
+		planned.Command = fmt.Sprintf("%s, %s", enableSharding(db), planned.Command)
+	}
+
+	return planned, nil
+}
+
+// shardedDatabase returns the database of shardCollection.
+func shardedDatabase(raw string) (string, bool) {
+	var cmd bson.D
+
+	if err := bson.UnmarshalExtJSON([]byte(raw), true, &cmd); err != nil || len(cmd) == 0 || cmd[0].Key != "shardCollection" {
+		return "", false
+	}
+
+	ns, ok := cmd[0].Value.(string)
+
+	if !ok {
+		return "", false
+	}
+
+	db, _, found := strings.Cut(ns, ".")
+
+	return db, found
+}
+
+func enableSharding(db string) string {
+	return fmt.Sprintf(`{"enableSharding":%q}`, db)
+}
+
+func isAlreadyInitialized(err error) bool {
+	var se mongo.ServerError
+
+	return errors.As(err, &se) && se.HasErrorCode(alreadyInitialized)
+}
+
+// vcoreAdmin translates admin commands written for az into native commands.
+type vcoreAdmin struct {
+	nativeAdmin
+}
+
+func (a vcoreAdmin) run(ctx context.Context, raw string, action Action) (bson.M, error) {
+	target, translated, err := a.translate(raw, action)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.m.runCommand(ctx, a.m.client.Database(target), translated)
+}
+
+func (a vcoreAdmin) plan(raw string, action Action) (PlannedStep, error) {
+	_, translated, err := a.translate(raw, action)
+
+	if err != nil {
+		return PlannedStep{}, err
+	}
+
+	return a.nativeAdmin.plan(translated, action)
+}
+
+// translate returns database and command to run, raw is returned as is unless it is written for az.
+// vCore shards with hashed keys only, and throughput does not apply.
+func (a vcoreAdmin) translate(raw string, action Action) (string, string, error) {
+	var probe map[string]json.RawMessage
+
+	if err := json.Unmarshal([]byte(raw), &probe); err != nil {
+		return "", "", err
+	}
+
+	_, collection := probe["collection"]
+	_, scope := probe["scope"]
+
+	if !collection && !scope {
+		return "admin", raw, nil
+	}
+
+	var cmd AzureCommand
+
+	if err := json.Unmarshal([]byte(raw), &cmd); err != nil {
+		return "", "", err
+	}
+
+	resolved, err := cmd.resolve(action)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	// Throughput does not apply to vCore.
+	if cmd.Action != "" || cmd.Scope == ScopeDatabase {
+		return "", "", fmt.Errorf("action %s of %s is not supported on %s", resolved, cmd.Scope, ProviderCosmosVCore)
+	}
+
+	if cmd.Collection == "" {
+		return "", "", fmt.Errorf("collection name is required")
+	}
+
+	db := a.m.db.Name()
+
+	switch action {
+	case Create:
+		if cmd.ShardKey == "" {
+			return "", "", fmt.Errorf("shard key is required")
+		}
+
+		return "admin", fmt.Sprintf(`{"shardCollection":%q,"key":{%q:"hashed"}}`, db+"."+cmd.Collection, cmd.ShardKey), nil
+	case Delete:
+		return db, fmt.Sprintf(`{"drop":%q}`, cmd.Collection), nil
+	default:
+		return "", "", fmt.Errorf("action %s is not supported on %s", action, ProviderCosmosVCore)
+	}
+}
+
+// azAdmin runs admin commands written as AzureCommand through Options.Executor.
+type azAdmin struct {
+	m *Migrator
+}
+
+func (a azAdmin) run(ctx context.Context, raw string, action Action) (bson.M, error) {
+	var cmd AzureCommand
+
+	if err := json.Unmarshal([]byte(raw), &cmd); err != nil {
+		return nil, err
+	}
+
+	a.m.log.Printf("%s", cmd.Description)
+
+	action, err := cmd.resolve(action)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Running a file again should not fail only because the collection or database has been created.
+	if action == Create {
+		ok, err := a.satisfied(ctx, cmd)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			note := fmt.Sprintf("%s already exists with the same settings", a.target(cmd))
+			a.m.log.Printf("%s", note)
+
+			return bson.M{"note": note}, nil
+		}
+	}
+
+	args, err := a.m.azArgs(cmd, action)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.m.log.Printf("%v", args)
+
+	if _, err := a.m.opts.Executor.Execute(ctx, args); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (a azAdmin) plan(raw string, action Action) (PlannedStep, error) {
+	var cmd AzureCommand
+
+	if err := json.Unmarshal([]byte(raw), &cmd); err != nil {
+		return PlannedStep{}, err
+	}
+
+	action, err := cmd.resolve(action)
+
+	if err != nil {
+		return PlannedStep{}, err
+	}
+
+	args, err := a.m.azArgs(cmd, action)
+
+	if err != nil {
+		return PlannedStep{}, err
+	}
+
+	return PlannedStep{Kind: StepAdmin, Az: args}, nil
+}
+
+func (azAdmin) external() bool {
+	return true
+}
+
+// unknownAdmin fails every admin command of an unsupported provider.
+type unknownAdmin struct {
+	p Provider
+}
+
+func (a unknownAdmin) run(context.Context, string, Action) (bson.M, error) {
+	return nil, a.p.validate()
+}
+
+func (a unknownAdmin) plan(string, Action) (PlannedStep, error) {
+	return PlannedStep{}, a.p.validate()
+}
+
+func (unknownAdmin) external() bool {
+	return false
+}
@@ -0,0 +1,125 @@
+package migration
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProviderValidate(t *testing.T) {
+	for _, p := range Providers {
+		if err := p.validate(); err != nil {
+			t.Errorf("should not fail, error %s", err)
+		}
+	}
+
+	if err := Provider("atlas").validate(); err == nil {
+		t.Errorf("should fail")
+	}
+}
+
+func TestPlanAdmin(t *testing.T) {
+	native := `{"shardCollection": "demo.users", "key": {"_id": "hashed"}}`
+	azure := `{"collection": "users", "shardKey": "_id", "throughput": 400}`
+
+	type pattern struct {
+		provider Provider
+		raw      string
+		action   Action
+		exp      string
+	}
+
+	pats := []pattern{
+		{ProviderLocal, native, Create, `admin: {"shardCollection":"demo.users","key":{"_id":"hashed"}}`},
+		{ProviderSharded, native, Create, `admin: {"enableSharding":"demo"}, {"shardCollection":"demo.users","key":{"_id":"hashed"}}`},
+		{ProviderSharded, `{"drop": "users"}`, Delete, `admin: {"drop":"users"}`},
+		{ProviderCosmosVCore, native, Create, `admin: {"shardCollection":"demo.users","key":{"_id":"hashed"}}`},
+		{ProviderCosmosVCore, azure, Create, `admin: {"shardCollection":"demo.users","key":{"_id":"hashed"}}`},
+		{ProviderCosmosVCore, azure, Delete, `admin: {"drop":"users"}`},
+		{ProviderCosmosRU, azure, Create, "admin: az cosmosdb mongodb collection create -g develop -a account -d demo -n users --shard _id --throughput 400"},
+		{ProviderCosmosRU, azure, Delete, "admin: az cosmosdb mongodb collection delete -g develop -a account -d demo -n users --yes"},
+		{ProviderCosmosRU, `{"action": "throughput update", "collection": "users", "throughput": 800}`, Create, "admin: az cosmosdb mongodb collection throughput update -g develop -a account -d demo -n users --throughput 800"},
+		{ProviderCosmosRU, `{"scope": "database", "throughput": 400}`, Create, "admin: az cosmosdb mongodb database create -g develop -a account -n demo --throughput 400"},
+		{ProviderCosmosRU, `{"action": "throughput migrate", "scope": "database", "autoScale": false}`, Delete, "admin: az cosmosdb mongodb database throughput migrate -g develop -a account -n demo --throughput-type manual"},
+	}
+
+	for idx, p := range pats {
+		m := newTestMigrator("demo", Options{Provider: p.provider, ResourceGroup: "develop", AccountName: "account"})
+
+		got, err := m.adminRunner().plan(p.raw, p.action)
+
+		if err != nil {
+			t.Errorf("case %d should not fail, error %s", idx, err)
+			continue
+		}
+
+		if got.String() != p.exp {
+			t.Errorf("case %d expected %s, got %s", idx, p.exp, got)
+		}
+	}
+
+	// Fails on unknown provider.
+	func() {
+		m := newTestMigrator("demo", Options{Provider: "atlas"})
+
+		if _, err := m.adminRunner().plan(native, Create); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+
+	// Fails on actions other than throughput changes given by the file.
+	func() {
+		for _, raw := range []string{
+			`{"action": "delete", "collection": "users"}`,
+			`{"action": "show", "collection": "users"}`,
+		} {
+			for _, p := range []Provider{ProviderCosmosRU, ProviderCosmosVCore} {
+				m := newTestMigrator("demo", Options{Provider: p, ResourceGroup: "develop", AccountName: "account"})
+
+				if _, err := m.adminRunner().plan(raw, Create); err == nil || !strings.Contains(err.Error(), "is not supported in files") {
+					t.Errorf("should fail on %s, error %s", p, err)
+				}
+			}
+		}
+
+		r := &Recorder{}
+		m := newTestMigrator("demo", Options{ResourceGroup: "develop", AccountName: "account", Executor: r})
+
+		if _, err := m.adminRunner().run(context.Background(), `{"action": "create", "collection": "users", "throughput": 400}`, Delete); err == nil || len(r.Calls()) != 0 {
+			t.Errorf("should fail without running az, got %v, error %s", r.Calls(), err)
+		}
+	}()
+
+	// Fails on vCore with throughput change and database creation, which has no RU.
+	func() {
+		m := newTestMigrator("demo", Options{Provider: ProviderCosmosVCore})
+
+		for _, raw := range []string{
+			`{"action": "throughput update", "collection": "users", "throughput": 800}`,
+			`{"scope": "database", "throughput": 400}`,
+		} {
+			if _, err := m.adminRunner().plan(raw, Create); err == nil {
+				t.Errorf("should fail, got %s", raw)
+			}
+		}
+	}()
+
+	// Fails on vCore without shard key.
+	func() {
+		m := newTestMigrator("demo", Options{Provider: ProviderCosmosVCore})
+
+		if _, err := m.adminRunner().plan(`{"collection": "users"}`, Create); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
+
+func TestExternal(t *testing.T) {
+	if !newTestMigrator("demo", Options{}).adminRunner().external() {
+		t.Errorf("should run outside of database by default")
+	}
+
+	if newTestMigrator("demo", Options{Provider: ProviderSharded}).adminRunner().external() {
+		t.Errorf("should run on database")
+	}
+}
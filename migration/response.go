@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Response represents the reply of a single step, recorded to migration history.
+type Response struct {
+	// Step is the position within Steps of the file.
+	Step    int    `bson:"step" json:"step"`
+	Summary string `bson:"summary" json:"summary"`
+	Reply   bson.M `bson:"reply,omitempty" json:"reply,omitempty"`
+}
+
+// internalReplyFields are attached by the server to every reply, and tell nothing about the command.
+var internalReplyFields = []string{"$clusterTime", "operationTime", "$gleStats", "lastCommittedOpTime", "electionId"}
+
+// checkReply returns error embedded in a reply, which the driver does not report when ok is 1.
+func checkReply(reply bson.M) error {
+	if ok, exists := number(reply["ok"]); exists && ok != 1 {
+		return fmt.Errorf("command failed, %v", reply["errmsg"])
+	}
+
+	if errs, ok := reply["writeErrors"].(bson.A); ok && len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+
+		for _, e := range errs {
+			if doc, ok := e.(bson.M); ok {
+				msgs = append(msgs, fmt.Sprintf("%v (code %v)", doc["errmsg"], doc["code"]))
+			}
+		}
+
+		return fmt.Errorf("%d write error(s), %s", len(errs), strings.Join(msgs, ", "))
+	}
+
+	if wce, ok := reply["writeConcernError"].(bson.M); ok {
+		return fmt.Errorf("write concern error, %v (code %v)", wce["errmsg"], wce["code"])
+	}
+
+	return nil
+}
+
+// summarize describes what a reply tells in a single line.
+func summarize(reply bson.M) string {
+	var out []string
+
+	if note, ok := reply["note"]; ok {
+		out = append(out, fmt.Sprintf("note: %v", note))
+	}
+
+	before, hasBefore := number(reply["numIndexesBefore"])
+	after, hasAfter := number(reply["numIndexesAfter"])
+
+	if hasBefore && hasAfter {
+		out = append(out, fmt.Sprintf("indexes %v -> %v", before, after))
+	}
+
+	if created, ok := reply["createdCollectionAutomatically"].(bool); ok && created {
+		out = append(out, "collection created")
+	}
+
+	for _, key := range []string{"n", "nModified", "nIndexesWas"} {
+		if v, ok := number(reply[key]); ok {
+			out = append(out, fmt.Sprintf("%s: %v", key, v))
+		}
+	}
+
+	if len(out) == 0 {
+		return "ok"
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// trimReply drops fields attached to every reply.
+func trimReply(reply bson.M) bson.M {
+	out := bson.M{}
+
+	for k, v := range reply {
+		out[k] = v
+	}
+
+	for _, k := range internalReplyFields {
+		delete(out, k)
+	}
+
+	return out
+}
+
+// number converts numeric value of a reply, whose type depends on the server.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCheckReply(t *testing.T) {
+	type pattern struct {
+		reply bson.M
+		fails bool
+	}
+
+	pats := []pattern{
+		{bson.M{"ok": float64(1)}, false},
+		{bson.M{"ok": int32(1), "n": int32(1)}, false},
+		{bson.M{"ok": float64(0), "errmsg": "failed"}, true},
+		{bson.M{"ok": float64(1), "writeErrors": bson.A{bson.M{"index": int32(0), "code": int32(11000), "errmsg": "duplicate key"}}}, true},
+		{bson.M{"ok": float64(1), "writeErrors": bson.A{}}, false},
+		{bson.M{"ok": float64(1), "writeConcernError": bson.M{"code": int32(64), "errmsg": "waiting for replication timed out"}}, true},
+	}
+
+	for idx, p := range pats {
+		if err := checkReply(p.reply); (err != nil) != p.fails {
+			t.Errorf("case %d expected failure %v, error %s", idx, p.fails, err)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	type pattern struct {
+		reply bson.M
+		exp   string
+	}
+
+	pats := []pattern{
+		{bson.M{"ok": float64(1)}, "ok"},
+		{
+			bson.M{"ok": float64(1), "numIndexesBefore": int32(2), "numIndexesAfter": int32(2), "note": "all indexes already exist"},
+			"note: all indexes already exist, indexes 2 -> 2",
+		},
+		{
+			bson.M{"ok": float64(1), "numIndexesBefore": int32(1), "numIndexesAfter": int32(2), "createdCollectionAutomatically": true},
+			"indexes 1 -> 2, collection created",
+		},
+		{bson.M{"ok": float64(1), "n": int32(3), "nModified": int64(2)}, "n: 3, nModified: 2"},
+	}
+
+	for idx, p := range pats {
+		if got := summarize(p.reply); got != p.exp {
+			t.Errorf("case %d expected %s, got %s", idx, p.exp, got)
+		}
+	}
+}
+
+func TestTrimReply(t *testing.T) {
+	reply := bson.M{"ok": float64(1), "n": int32(1), "$clusterTime": bson.M{}, "operationTime": int64(1)}
+
+	got := trimReply(reply)
+
+	if len(got) != 2 || got["n"] != int32(1) {
+		t.Errorf("should drop internal fields, got %#v", got)
+	}
+
+	if len(reply) != 4 {
+		t.Errorf("should not modify the given reply, got %#v", reply)
+	}
+}
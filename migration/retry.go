@@ -0,0 +1,127 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// throttledCode is returned by Cosmos DB when request rate exceeds provisioned throughput.
+const throttledCode = 16500
+
+// retryAfterPattern extracts the hint Cosmos DB puts in the message, e.g. "Error=16500, RetryAfterMs=12".
+var retryAfterPattern = regexp.MustCompile(`RetryAfterMs=(\d+)`)
+
+// Retry configures retry of requests throttled by Cosmos DB.
+type Retry struct {
+	// MaxRetries is the retry budget of a single operation, 5 by default, negative disables retry.
+	MaxRetries int
+	// BaseDelay is the first backoff, doubled on each retry, 500 milliseconds by default.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, 30 seconds by default. RetryAfterMs given by the server is always honored.
+	MaxDelay time.Duration
+}
+
+func (r Retry) withDefaults() Retry {
+	if r.MaxRetries == 0 {
+		r.MaxRetries = 5
+	}
+
+	if r.BaseDelay == 0 {
+		r.BaseDelay = 500 * time.Millisecond
+	}
+
+	if r.MaxDelay == 0 {
+		r.MaxDelay = 30 * time.Second
+	}
+
+	return r
+}
+
+// backoff returns the wait before the given retry, counted from zero.
+func (r Retry) backoff(attempt int, hint time.Duration) time.Duration {
+	d := r.MaxDelay
+
+	// Stop doubling before it overflows.
+	if attempt < 30 {
+		if exp := r.BaseDelay << attempt; exp > 0 && exp < r.MaxDelay {
+			d = exp
+		}
+	}
+
+	if hint > d {
+		return hint
+	}
+
+	return d
+}
+
+// throttled reports whether err is caused by throttling, along with RetryAfterMs when given.
+func throttled(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	// Error of AzCLI contains stderr, where az reports throttling.
+	msg := err.Error()
+
+	var ae *ARMError
+
+	// Azure Resource Manager tells the wait by Retry-After header.
+	if errors.As(err, &ae) && ae.StatusCode == http.StatusTooManyRequests {
+		return ae.RetryAfter, true
+	}
+
+	var se mongo.ServerError
+
+	ok := errors.As(err, &se) && se.HasErrorCode(throttledCode)
+	ok = ok || strings.Contains(msg, "TooManyRequests") || strings.Contains(msg, "Request rate is large")
+
+	if !ok {
+		return 0, false
+	}
+
+	var hint time.Duration
+
+	if got := retryAfterPattern.FindStringSubmatch(msg); got != nil {
+		if ms, err := strconv.Atoi(got[1]); err == nil {
+			hint = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return hint, true
+}
+
+// withRetry runs fn again while it is throttled, until the retry budget runs out.
+// Other errors are returned immediately.
+func (m *Migrator) withRetry(ctx context.Context, op string, fn func() error) error {
+	r := m.opts.Retry
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+
+		hint, ok := throttled(err)
+
+		if !ok || r.MaxRetries < 0 || attempt >= r.MaxRetries {
+			return err
+		}
+
+		m.throttles.Add(1)
+
+		wait := r.backoff(attempt, hint)
+
+		m.log.Printf("throttled on %s, retrying in %s (%d/%d)", op, wait, attempt+1, r.MaxRetries)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
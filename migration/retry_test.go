@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestThrottled(t *testing.T) {
+	type pattern struct {
+		err  error
+		hint time.Duration
+		exp  bool
+	}
+
+	pats := []pattern{
+		{nil, 0, false},
+		{errors.New("connection refused"), 0, false},
+		{mongo.CommandError{Code: 11000, Message: "duplicate key"}, 0, false},
+		{mongo.CommandError{Code: throttledCode, Message: "Error=16500, RetryAfterMs=12, Details='Response status code does not indicate success: TooManyRequests (429)'"}, 12 * time.Millisecond, true},
+		{mongo.CommandError{Code: throttledCode, Message: "Request rate is large"}, 0, true},
+		{errors.New("(TooManyRequests) Request rate is large"), 0, true},
+	}
+
+	for idx, p := range pats {
+		hint, ok := throttled(p.err)
+
+		if ok != p.exp || hint != p.hint {
+			t.Errorf("case %d expected %v %s, got %v %s", idx, p.exp, p.hint, ok, hint)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := Retry{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	type pattern struct {
+		attempt int
+		hint    time.Duration
+		exp     time.Duration
+	}
+
+	pats := []pattern{
+		{0, 0, 100 * time.Millisecond},
+		{2, 0, 400 * time.Millisecond},
+		{4, 0, time.Second},
+		{100, 0, time.Second},
+		{0, 300 * time.Millisecond, 300 * time.Millisecond},
+		{4, 5 * time.Second, 5 * time.Second},
+	}
+
+	for idx, p := range pats {
+		if got := r.backoff(p.attempt, p.hint); got != p.exp {
+			t.Errorf("case %d expected %s, got %s", idx, p.exp, got)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator("demo", Options{Retry: Retry{MaxRetries: 3, BaseDelay: time.Millisecond}})
+
+	throttle := mongo.CommandError{Code: throttledCode, Message: "RetryAfterMs=1"}
+
+	// OK after throttled twice.
+	func() {
+		calls := 0
+
+		err := m.withRetry(ctx, "test", func() error {
+			calls++
+
+			if calls < 3 {
+				return throttle
+			}
+
+			return nil
+		})
+
+		if err != nil || calls != 3 {
+			t.Errorf("should succeed on third call, got %d, error %s", calls, err)
+		}
+	}()
+
+	// Fails fast on non-retryable error.
+	func() {
+		calls := 0
+
+		err := m.withRetry(ctx, "test", func() error {
+			calls++
+			return errors.New("invalid command")
+		})
+
+		if err == nil || calls != 1 {
+			t.Errorf("should not retry, got %d, error %s", calls, err)
+		}
+	}()
+
+	// Fails when budget runs out.
+	func() {
+		calls := 0
+
+		err := m.withRetry(ctx, "test", func() error {
+			calls++
+			return throttle
+		})
+
+		if err == nil || calls != 4 {
+			t.Errorf("should stop after 3 retries, got %d, error %s", calls, err)
+		}
+	}()
+
+	// Disabled by negative budget.
+	func() {
+		disabled := newTestMigrator("demo", Options{Retry: Retry{MaxRetries: -1}})
+		calls := 0
+
+		err := disabled.withRetry(ctx, "test", func() error {
+			calls++
+			return throttle
+		})
+
+		if err == nil || calls != 1 {
+			t.Errorf("should not retry, got %d, error %s", calls, err)
+		}
+	}()
+}
@@ -0,0 +1,189 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// State represents where a single version stands against the database.
+type State string
+
+const (
+	// StateApplied means the version is at or before the current pointer.
+	StateApplied State = "applied"
+	// StatePending means the version is waiting for `migrate up`.
+	StatePending State = "pending"
+	// StateMissing means the version is recorded in history but missing from disk.
+	StateMissing State = "missing"
+	// StateSkipped means the version sorts before the current pointer but has never been applied.
+	StateSkipped State = "skipped"
+)
+
+// VersionState represents a single row of status report.
+type VersionState struct {
+	Version   string     `json:"version"`
+	State     State      `json:"state"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+	Modified  bool       `json:"modified,omitempty"`
+}
+
+// StatusReport represents the result of Status.
+type StatusReport struct {
+	Current  string         `json:"current"`
+	Versions []VersionState `json:"versions"`
+}
+
+/*
+Status compares migration history with files within the directory.
+
+マイグレーション履歴とディレクトリ内のファイルを比較します。
+*/
+func (m *Migrator) Status(ctx context.Context) (*StatusReport, error) {
+	entries, err := m.History(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cur, ok := latest(entries)
+
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve migration history")
+	}
+
+	actual, err := m.dirChecksums()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildReport(cur, entries, actual), nil
+}
+
+// buildReport builds a report from history and checksum of each file on disk.
+func buildReport(cur string, entries []Entry, actual map[string]string) *StatusReport {
+	// Keep the last time each version has been applied.
+	appliedAt := map[string]time.Time{}
+	recorded := map[string]bool{}
+
+	for _, e := range entries {
+		if e.Status == StatusRolledBack {
+			delete(recorded, e.Version)
+			delete(appliedAt, e.Version)
+			continue
+		}
+
+		if e.Status == StatusInit || !e.movesPointer() {
+			continue
+		}
+
+		recorded[e.Version] = true
+
+		if e.Status == StatusApplied {
+			appliedAt[e.Version] = e.AppliedAt
+		}
+	}
+
+	modified := map[string]bool{}
+
+	for _, d := range findDrifts(entries, actual) {
+		modified[d.Version] = true
+	}
+
+	applied, floor := appliedVersions(entries)
+
+	out := &StatusReport{Current: cur}
+
+	for f := range actual {
+		row := VersionState{Version: f, State: StatePending, Modified: modified[f]}
+
+		// Files are applied in lexical order, same as Next.
+		if cur != migrationInitValue && f <= cur {
+			row.State = StateApplied
+
+			if f != cur && !isApplied(applied, floor, f) {
+				row.State = StateSkipped
+			}
+
+			if at, ok := appliedAt[f]; ok {
+				row.AppliedAt = &at
+			}
+		}
+
+		out.Versions = append(out.Versions, row)
+	}
+
+	for v := range recorded {
+		if _, ok := actual[v]; ok {
+			continue
+		}
+
+		row := VersionState{Version: v, State: StateMissing}
+
+		if at, ok := appliedAt[v]; ok {
+			row.AppliedAt = &at
+		}
+
+		out.Versions = append(out.Versions, row)
+	}
+
+	sort.SliceStable(out.Versions, func(i, j int) bool {
+		return out.Versions[i].Version < out.Versions[j].Version
+	})
+
+	return out
+}
+
+// Modified returns versions whose content has changed since applied.
+func (r *StatusReport) Modified() []string {
+	var out []string
+
+	for _, v := range r.Versions {
+		if v.Modified {
+			out = append(out, v.Version)
+		}
+	}
+
+	return out
+}
+
+// Write prints the report in the given format, either "table" or "json".
+func (r *StatusReport) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(r)
+	case "table":
+		fmt.Fprintf(w, "current: %s\n\n", r.Current)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tNOTE")
+
+		for _, v := range r.Versions {
+			at := "-"
+
+			if v.AppliedAt != nil {
+				at = v.AppliedAt.Local().Format(time.RFC3339)
+			}
+
+			note := ""
+
+			if v.Modified {
+				note = "checksum mismatch"
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Version, v.State, at, note)
+		}
+
+		return tw.Flush()
+	default:
+		return fmt.Errorf("invalid format %s", format)
+	}
+}
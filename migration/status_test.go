@@ -0,0 +1,131 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildReport(t *testing.T) {
+	now := time.Now()
+
+	entries := []Entry{
+		{Version: "0", Status: StatusInit, AppliedAt: now},
+		{Version: "000000001_users.json", Status: StatusApplied, AppliedAt: now.Add(time.Second)},
+		{Version: "000000000_removed.json", Status: StatusApplied, AppliedAt: now.Add(2 * time.Second)},
+		{Version: "000000002_admins.json", Status: StatusFailed, AppliedAt: now.Add(3 * time.Second)},
+	}
+	actual := map[string]string{"000000001_users.json": "a", "000000002_admins.json": "b"}
+
+	got := buildReport("000000001_users.json", entries, actual)
+
+	exp := []VersionState{
+		{Version: "000000000_removed.json", State: StateMissing},
+		{Version: "000000001_users.json", State: StateApplied},
+		{Version: "000000002_admins.json", State: StatePending},
+	}
+
+	if len(got.Versions) != len(exp) {
+		t.Errorf("should be identical, got %#v", got.Versions)
+		return
+	}
+
+	for idx, e := range exp {
+		if got.Versions[idx].Version != e.Version || got.Versions[idx].State != e.State {
+			t.Errorf("case %d expected %#v, got %#v", idx, e, got.Versions[idx])
+		}
+	}
+
+	if got.Versions[1].AppliedAt == nil {
+		t.Errorf("applied version should have appliedAt")
+	}
+
+	if got.Versions[2].AppliedAt != nil {
+		t.Errorf("pending version should not have appliedAt")
+	}
+}
+
+func TestBuildReportModified(t *testing.T) {
+	entries := []Entry{
+		{Version: "000000001_users.json", Status: StatusApplied, Checksum: "a"},
+	}
+
+	got := buildReport("000000001_users.json", entries, map[string]string{"000000001_users.json": "b"})
+
+	if len(got.Modified()) != 1 {
+		t.Errorf("should be reported as modified, got %#v", got.Versions)
+	}
+}
+
+func TestBuildReportSkipped(t *testing.T) {
+	entries := []Entry{
+		{Version: "0", Status: StatusInit},
+		{Version: "000000002_admins.json", Status: StatusApplied},
+	}
+	actual := map[string]string{"000000001_users.json": "a", "000000002_admins.json": "b"}
+
+	got := buildReport("000000002_admins.json", entries, actual)
+
+	if len(got.Versions) != 2 || got.Versions[0].State != StateSkipped || got.Versions[1].State != StateApplied {
+		t.Errorf("should be reported as skipped, got %#v", got.Versions)
+	}
+}
+
+func TestBuildReportAfterInit(t *testing.T) {
+	got := buildReport(migrationInitValue, nil, map[string]string{"000000001_users.json": "a"})
+
+	if len(got.Versions) != 1 || got.Versions[0].State != StatePending {
+		t.Errorf("everything should be pending, got %#v", got.Versions)
+	}
+}
+
+func TestStatusReportWrite(t *testing.T) {
+	r := &StatusReport{
+		Current:  "000000001_users.json",
+		Versions: []VersionState{{Version: "000000001_users.json", State: StateApplied}},
+	}
+
+	// OK with table.
+	func() {
+		var buf bytes.Buffer
+
+		if err := r.Write(&buf, "table"); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		if !strings.Contains(buf.String(), "000000001_users.json  applied") {
+			t.Errorf("should contain row, got %s", buf.String())
+		}
+	}()
+
+	// OK with json.
+	func() {
+		var buf bytes.Buffer
+
+		if err := r.Write(&buf, "json"); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		var got StatusReport
+
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Errorf("should be valid JSON, error %s", err)
+			return
+		}
+
+		if got.Current != r.Current {
+			t.Errorf("should be identical, got %#v", got)
+		}
+	}()
+
+	// Fails on unknown format.
+	func() {
+		if err := r.Write(&bytes.Buffer{}, "yaml"); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
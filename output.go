@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"sios.tech/covas/migrate/migration"
+)
+
+// Output formats of --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// output writes messages of the CLI, either as text or as one JSON event per line.
+// It also serves as migration.Logger, so messages of Migrator follow the same format.
+type output struct {
+	w    io.Writer
+	json bool
+	mu   sync.Mutex
+	// failed is set once a failed event has been written.
+	failed bool
+}
+
+// event is a single line of JSON output, messages without a phase are reported as "log".
+type event struct {
+	migration.Event
+	Message string                  `json:"message,omitempty"`
+	Status  *migration.StatusReport `json:"status,omitempty"`
+}
+
+func (o *output) setFormat(format string) error {
+	switch format {
+	case outputText:
+		o.json = false
+	case outputJSON:
+		o.json = true
+	default:
+		return fmt.Errorf("output must be either %s or %s", outputText, outputJSON)
+	}
+
+	return nil
+}
+
+func (o *output) write(e event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// A failure is reported once, either by Migrator or by the command.
+	if e.Phase == migration.PhaseFailed {
+		if o.failed {
+			return
+		}
+
+		o.failed = true
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b, err := json.Marshal(e)
+
+	if err != nil {
+		fmt.Fprintf(o.w, "failed to encode output, %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(o.w, "%s\n", b)
+}
+
+// Printf writes a free-form message.
+func (o *output) Printf(format string, v ...any) {
+	if !o.json {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		fmt.Fprintf(o.w, format, v...)
+
+		if !strings.HasSuffix(format, "\n") {
+			fmt.Fprintln(o.w)
+		}
+
+		return
+	}
+
+	msg := strings.TrimSpace(fmt.Sprintf(format, v...))
+
+	o.write(event{Event: migration.Event{Phase: "log"}, Message: msg})
+}
+
+// Event writes a structured event, text output relies on messages instead.
+func (o *output) Event(e migration.Event) {
+	if o.json {
+		o.write(event{Event: e})
+	}
+}
+
+// Status reports the result of status as a single event, the report is written by the caller on text.
+func (o *output) Status(r *migration.StatusReport) {
+	if o.json {
+		o.write(event{Event: migration.Event{Phase: migration.PhaseStatus}, Status: r})
+	}
+}
+
+// Failed reports error which stops the command.
+// On JSON, nothing is written when Migrator has already reported the failure as an event.
+func (o *output) Failed(err error) {
+	if o.json {
+		o.write(event{Event: migration.Event{Phase: migration.PhaseFailed, Error: err.Error()}})
+		return
+	}
+
+	o.Printf("failed, %s ", err)
+}
+
+// Done reports the command has finished.
+func (o *output) Done() {
+	if o.json {
+		o.write(event{Event: migration.Event{Phase: "done"}})
+		return
+	}
+
+	o.Printf("done!")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"sios.tech/covas/migrate/migration"
+)
+
+func TestOutput(t *testing.T) {
+	// OK with text.
+	func() {
+		var buf bytes.Buffer
+
+		o := &output{w: &buf}
+
+		o.Printf("applying %s", "000000001_users.json")
+		o.Event(migration.Event{Phase: migration.PhaseCurrent, Version: "0"})
+		o.Failed(errors.New("broken"))
+		o.Done()
+
+		exp := "applying 000000001_users.json\nfailed, broken \ndone!\n"
+
+		if buf.String() != exp {
+			t.Errorf("should be identical, got %q", buf.String())
+		}
+	}()
+
+	// OK with JSON, every line is an event.
+	func() {
+		var buf bytes.Buffer
+
+		o := &output{w: &buf}
+
+		if err := o.setFormat(outputJSON); err != nil {
+			t.Errorf("should not fail, error %s", err)
+			return
+		}
+
+		o.Printf("applying %s \n", "000000001_users.json")
+		o.Event(migration.Event{Phase: migration.PhaseCommand, Version: "000000001_users.json", Step: 1, DurationMs: 12})
+		o.Failed(errors.New("broken"))
+		o.Done()
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+		if len(lines) != 4 {
+			t.Errorf("should print 4 lines, got %q", buf.String())
+			return
+		}
+
+		exp := []string{"log", "command", "failed", "done"}
+
+		for idx, l := range lines {
+			var got map[string]any
+
+			if err := json.Unmarshal([]byte(l), &got); err != nil {
+				t.Errorf("line %d should be JSON, error %s", idx, err)
+				continue
+			}
+
+			if got["phase"] != exp[idx] || got["time"] == nil {
+				t.Errorf("line %d expected %s, got %v", idx, exp[idx], got)
+			}
+		}
+
+		if !strings.Contains(lines[0], `"message":"applying 000000001_users.json"`) || !strings.Contains(lines[2], `"error":"broken"`) {
+			t.Errorf("should contain details, got %q", buf.String())
+		}
+	}()
+
+	// OK with a single failed event, when Migrator has reported the failure.
+	func() {
+		var buf bytes.Buffer
+
+		o := &output{w: &buf}
+		o.setFormat(outputJSON)
+
+		o.Event(migration.Event{Phase: migration.PhaseFailed, Version: "000000001_users.json", Step: 1, Error: "broken"})
+		o.Failed(errors.New("broken"))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+		if len(lines) != 1 || !strings.Contains(lines[0], `"version":"000000001_users.json"`) {
+			t.Errorf("should print a line, got %q", buf.String())
+		}
+	}()
+
+	// OK with status report as a single event.
+	func() {
+		var buf bytes.Buffer
+
+		o := &output{w: &buf}
+
+		// Nothing is written on text, the report is written by the caller.
+		o.Status(&migration.StatusReport{Current: "0"})
+
+		if buf.Len() != 0 {
+			t.Errorf("should not print, got %q", buf.String())
+		}
+
+		o.setFormat(outputJSON)
+		o.Status(&migration.StatusReport{Current: "000000001_users.json", Versions: []migration.VersionState{{Version: "000000001_users.json", Modified: true}}})
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+		if len(lines) != 1 || !strings.Contains(lines[0], `"phase":"status"`) || !strings.Contains(lines[0], `"status":{"current":"000000001_users.json"`) {
+			t.Errorf("should print a line, got %q", buf.String())
+		}
+	}()
+
+	// Fails on unknown format.
+	func() {
+		if err := (&output{}).setFormat("xml"); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}
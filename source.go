@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// openSource returns JSON files given by --dir, which is either a directory or a zip or tar archive.
+// Files must be placed at the root of an archive. Returned close releases the archive.
+func openSource(dir string) (fs.FS, func() error, error) {
+	noop := func() error { return nil }
+
+	info, err := os.Stat(dir)
+
+	// Keep the error of a missing directory to Migrator, same as before.
+	if err != nil || info.IsDir() {
+		return os.DirFS(dir), noop, nil
+	}
+
+	lower := strings.ToLower(dir)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		r, err := zip.OpenReader(dir)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open zip, %s", err)
+		}
+
+		return r, r.Close, nil
+	case strings.HasSuffix(lower, ".tar"):
+		fsys, err := openTar(dir, false)
+
+		return fsys, noop, err
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fsys, err := openTar(dir, true)
+
+		return fsys, noop, err
+	default:
+		return nil, nil, fmt.Errorf("%s is neither a directory nor a zip or tar archive", dir)
+	}
+}
+
+// openTar reads tar archive into memory.
+// Standard library has no fs.FS for tar, so the content is repacked as zip.
+func openTar(name string, gzipped bool) (fs.FS, error) {
+	f, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var src io.Reader = f
+
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip, %s", err)
+		}
+
+		defer gz.Close()
+
+		src = gz
+	}
+
+	var buf bytes.Buffer
+
+	tr := tar.NewReader(src)
+	zw := zip.NewWriter(&buf)
+
+	for {
+		h, err := tr.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar, %s", err)
+		}
+
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		w, err := zw.Create(strings.TrimPrefix(h.Name, "./"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := io.Copy(w, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
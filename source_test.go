@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var sourceFiles = map[string]string{
+	"000000001_users.json":  `{"command": {"create": "users"}}`,
+	"000000002_admins.json": `{"command": {"create": "admins"}}`,
+}
+
+func writeZip(t *testing.T, name string) {
+	f, err := os.Create(name)
+
+	if err != nil {
+		t.Fatalf("should not fail, error %s", err)
+	}
+
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	for n, body := range sourceFiles {
+		w, err := zw.Create(n)
+
+		if err != nil {
+			t.Fatalf("should not fail, error %s", err)
+		}
+
+		io.WriteString(w, body)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("should not fail, error %s", err)
+	}
+}
+
+func writeTar(t *testing.T, name string, gzipped bool) {
+	f, err := os.Create(name)
+
+	if err != nil {
+		t.Fatalf("should not fail, error %s", err)
+	}
+
+	defer f.Close()
+
+	var dst io.Writer = f
+
+	if gzipped {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+
+		dst = gz
+	}
+
+	tw := tar.NewWriter(dst)
+
+	for n, body := range sourceFiles {
+		h := &tar.Header{Name: "./" + n, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}
+
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatalf("should not fail, error %s", err)
+		}
+
+		io.WriteString(tw, body)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("should not fail, error %s", err)
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	dir := t.TempDir()
+
+	writeZip(t, filepath.Join(dir, "migrations.zip"))
+	writeTar(t, filepath.Join(dir, "migrations.tar"), false)
+	writeTar(t, filepath.Join(dir, "migrations.tar.gz"), true)
+
+	// OKs
+	for _, name := range []string{"./examples", "migrations.zip", "migrations.tar", "migrations.tar.gz"} {
+		given := name
+
+		if name != "./examples" {
+			given = filepath.Join(dir, name)
+		}
+
+		fsys, closeSource, err := openSource(given)
+
+		if err != nil {
+			t.Errorf("%s should not fail, error %s", name, err)
+			continue
+		}
+
+		paths, err := fs.Glob(fsys, "*.json")
+
+		if err != nil || len(paths) < 2 {
+			t.Errorf("%s should contain JSON files, got %v, error %s", name, paths, err)
+		}
+
+		if name != "./examples" {
+			got, err := fs.ReadFile(fsys, "000000002_admins.json")
+
+			if err != nil || string(got) != sourceFiles["000000002_admins.json"] {
+				t.Errorf("%s should be identical, got %s, error %s", name, got, err)
+			}
+		}
+
+		if err := closeSource(); err != nil {
+			t.Errorf("%s should not fail, error %s", name, err)
+		}
+	}
+
+	// Fails on unknown file.
+	func() {
+		given := filepath.Join(dir, "migrations.txt")
+
+		if err := os.WriteFile(given, []byte("text"), 0o644); err != nil {
+			t.Fatalf("should not fail, error %s", err)
+		}
+
+		if _, _, err := openSource(given); err == nil {
+			t.Errorf("should fail")
+		}
+	}()
+}